@@ -0,0 +1,13 @@
+package domain
+
+import "time"
+
+// CodePolicy controls how verification codes of one business type
+// are generated, how long they live and how often they can be sent and checked
+type CodePolicy struct {
+	Length         int
+	Alphabet       string
+	TTL            time.Duration
+	ResendInterval time.Duration
+	MaxAttempts    int
+}
@@ -0,0 +1,43 @@
+package domain
+
+import "time"
+
+type SMSStatus uint8
+
+const (
+	SMSStatusUnknown     SMSStatus = iota
+	SMSStatusSent                  // accepted by the provider
+	SMSStatusFailed                // rejected by the provider or not sent at all
+	SMSStatusDelivered             // provider receipt: delivered to the handset
+	SMSStatusUndelivered           // provider receipt: delivery failed
+)
+
+func (s SMSStatus) String() string {
+	switch s {
+	case SMSStatusSent:
+		return "SENT"
+	case SMSStatusFailed:
+		return "FAILED"
+	case SMSStatusDelivered:
+		return "DELIVERED"
+	case SMSStatusUndelivered:
+		return "UNDELIVERED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// SMSLog is the audit record of a single SMS sent to one phone number.
+// Phone is the raw number when recording and the masked number when read back.
+type SMSLog struct {
+	Id            int64
+	Phone         string
+	TplId         string
+	Provider      string
+	ProviderMsgId string
+	Latency       time.Duration
+	Status        SMSStatus
+	ErrMsg        string
+	Ctime         time.Time
+	Utime         time.Time
+}
@@ -5,4 +5,6 @@ type User struct {
 	Phone    string
 	Email    string
 	Password string
+	// Locale is the preferred language of messages and SMS, empty if never set
+	Locale string
 }
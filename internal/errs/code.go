@@ -0,0 +1,59 @@
+// Package errs is the registry of business error codes returned in ginx.Result.
+// A code is the HTTP status it is sent with followed by a 3 digit sequence.
+// Codes are part of the API: never change or reuse one, only add new ones.
+// Their messages are in the i18n catalog.
+package errs
+
+const (
+	OK = 0
+
+	// 200xxx only select the message of a successful result, whose code stays OK
+	SignupOK    = 200001
+	LoginOK     = 200002
+	LogoutOK    = 200003
+	CodeSentOK  = 200004
+	SMSSentOK   = 200005
+	LocaleSetOK = 200006
+
+	// InvalidInput the request body cannot be parsed, also sent by ginx.WrapBody
+	InvalidInput           = 400000
+	EmailFormat            = 400001
+	PasswordFormat         = 400002
+	PasswordMismatch       = 400003
+	PhoneRequired          = 400004
+	UnsupportedCodeChannel = 400005
+	CodeIncorrect          = 400006
+	CodeExpired            = 400007
+	SMSTemplateRequired    = 400008
+	ReceiptStatusUnknown   = 400009
+	InvalidTimeRange       = 400010
+	UnsupportedLocale      = 400011
+
+	Unauthorized          = 401000
+	InvalidUserOrPassword = 401001
+	InvalidServiceToken   = 401002
+	InvalidReceiptSign    = 401003
+
+	// Forbidden the user is not allowed to use the route, e.g. not an admin
+	Forbidden             = 403000
+	SMSTemplateNotAllowed = 403001
+
+	UserNotFound  = 404001
+	EmailConflict = 409001
+
+	// RateLimited rejected by the rate limit middleware
+	RateLimited              = 429000
+	CodeSendTooFrequent      = 429001
+	CodeTooManyAttempts      = 429002
+	PhoneHourlyQuotaExceeded = 429003
+	PhoneDailyQuotaExceeded  = 429004
+	IPDailyQuotaExceeded     = 429005
+	BizDailyQuotaExceeded    = 429006
+	SMSQuotaExceeded         = 429007
+
+	Internal = 500000
+
+	SMSLoginUnavailable = 503001
+	SystemBusy          = 503002
+	NotReady            = 503003
+)
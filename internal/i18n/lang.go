@@ -0,0 +1,64 @@
+// Package i18n holds the user-facing message catalog and picks the language
+// of a request.
+package i18n
+
+import (
+	"context"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// Lang is a BCP 47 language tag with a message catalog
+type Lang string
+
+const (
+	En   Lang = "en"
+	ZhCN Lang = "zh-CN"
+)
+
+// Default is used when nothing the client accepts is supported
+const Default = En
+
+// Supported in order of preference when a client accepts several equally
+var Supported = []Lang{En, ZhCN}
+
+var matcher = language.NewMatcher([]language.Tag{language.English, language.SimplifiedChinese})
+
+// Parse accepts a supported language, case-insensitively
+func Parse(s string) (Lang, bool) {
+	for _, l := range Supported {
+		if strings.EqualFold(string(l), s) {
+			return l, true
+		}
+	}
+	return "", false
+}
+
+// Match picks the supported language closest to an Accept-Language header
+func Match(acceptLanguage string) Lang {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return Default
+	}
+	_, idx, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return Default
+	}
+	return Supported[idx]
+}
+
+type langKey struct{}
+
+// WithLang carries the language of the user a request is for, e.g. down to the SMS templates
+func WithLang(ctx context.Context, lang Lang) context.Context {
+	return context.WithValue(ctx, langKey{}, lang)
+}
+
+// FromContext returns the language set by WithLang, or Default
+func FromContext(ctx context.Context) Lang {
+	if lang, ok := ctx.Value(langKey{}).(Lang); ok {
+		return lang
+	}
+	return Default
+}
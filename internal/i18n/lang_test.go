@@ -0,0 +1,42 @@
+package i18n
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cyvqet/connectify/internal/errs"
+)
+
+func TestMatch(t *testing.T) {
+	testCases := []struct {
+		name           string
+		acceptLanguage string
+		want           Lang
+	}{
+		{name: "empty", want: Default},
+		{name: "english", acceptLanguage: "en-US,en;q=0.9", want: En},
+		{name: "simplified chinese", acceptLanguage: "zh-CN,zh;q=0.9,en;q=0.8", want: ZhCN},
+		{name: "chinese without region", acceptLanguage: "zh", want: ZhCN},
+		{name: "weights", acceptLanguage: "en;q=0.5,zh-CN;q=0.9", want: ZhCN},
+		{name: "unsupported", acceptLanguage: "fr-FR", want: Default},
+		{name: "malformed", acceptLanguage: ";;q=x", want: Default},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Match(tc.acceptLanguage))
+		})
+	}
+}
+
+func TestCatalogs(t *testing.T) {
+	// Every language translates every code
+	for lang, catalog := range catalogs {
+		for code := range catalogs[Default] {
+			_, ok := catalog[code]
+			assert.True(t, ok, "%s misses %d", lang, code)
+		}
+	}
+	assert.Equal(t, "system error", Msg(En, 123))
+	assert.Equal(t, "系统错误", Msg(ZhCN, errs.Internal))
+}
@@ -0,0 +1,120 @@
+package i18n
+
+import "github.com/cyvqet/connectify/internal/errs"
+
+var catalogs = map[Lang]map[int]string{
+	En:   en,
+	ZhCN: zhCN,
+}
+
+// Msg is the message of code in lang. Missing translations fall back to
+// Default, unknown codes read as a system error.
+func Msg(lang Lang, code int) string {
+	if msg, ok := catalogs[lang][code]; ok {
+		return msg
+	}
+	if msg, ok := catalogs[Default][code]; ok {
+		return msg
+	}
+	return Msg(lang, errs.Internal)
+}
+
+var en = map[int]string{
+	errs.OK: "ok",
+
+	errs.SignupOK:    "registration successful",
+	errs.LoginOK:     "login successful",
+	errs.LogoutOK:    "logout successful",
+	errs.CodeSentOK:  "send successful",
+	errs.SMSSentOK:   "send successful",
+	errs.LocaleSetOK: "language updated",
+
+	errs.InvalidInput:           "invalid request",
+	errs.EmailFormat:            "email format error",
+	errs.PasswordFormat:         "password format error",
+	errs.PasswordMismatch:       "two input passwords are not consistent",
+	errs.PhoneRequired:          "please input phone number",
+	errs.UnsupportedCodeChannel: "unsupported channel",
+	errs.CodeIncorrect:          "verification code is incorrect, please check and try again",
+	errs.CodeExpired:            "verification code has expired, please get a new one",
+	errs.SMSTemplateRequired:    "tplId and numbers are required",
+	errs.ReceiptStatusUnknown:   "unknown receipt status",
+	errs.InvalidTimeRange:       "invalid start or end",
+	errs.UnsupportedLocale:      "unsupported language",
+
+	errs.Unauthorized:          "unauthorized",
+	errs.InvalidUserOrPassword: "username/password error",
+	errs.InvalidServiceToken:   "invalid service token",
+	errs.InvalidReceiptSign:    "invalid receipt signature",
+
+	errs.Forbidden:             "forbidden",
+	errs.SMSTemplateNotAllowed: "template not allowed",
+
+	errs.UserNotFound:  "user not found",
+	errs.EmailConflict: "email conflict",
+
+	errs.RateLimited:              "too many requests, please try again later",
+	errs.CodeSendTooFrequent:      "sms send too frequently, please try again later",
+	errs.CodeTooManyAttempts:      "too many incorrect attempts, please get a new verification code",
+	errs.PhoneHourlyQuotaExceeded: "too many codes sent to this phone, please try again in an hour",
+	errs.PhoneDailyQuotaExceeded:  "daily code limit reached for this phone, please try again tomorrow",
+	errs.IPDailyQuotaExceeded:     "too many codes requested from your network today, please try again tomorrow",
+	errs.BizDailyQuotaExceeded:    "sms login is busy, please try again later or log in with email",
+	errs.SMSQuotaExceeded:         "sms quota exceeded",
+
+	errs.Internal: "system error",
+
+	errs.SMSLoginUnavailable: "sms login is temporarily unavailable, please log in with email",
+	errs.SystemBusy:          "system busy, please try again later",
+	errs.NotReady:            "service not ready",
+}
+
+var zhCN = map[int]string{
+	errs.OK: "成功",
+
+	errs.SignupOK:    "注册成功",
+	errs.LoginOK:     "登录成功",
+	errs.LogoutOK:    "退出登录成功",
+	errs.CodeSentOK:  "发送成功",
+	errs.SMSSentOK:   "发送成功",
+	errs.LocaleSetOK: "语言已更新",
+
+	errs.InvalidInput:           "请求参数错误",
+	errs.EmailFormat:            "邮箱格式错误",
+	errs.PasswordFormat:         "密码格式错误",
+	errs.PasswordMismatch:       "两次输入的密码不一致",
+	errs.PhoneRequired:          "请输入手机号码",
+	errs.UnsupportedCodeChannel: "不支持的验证码渠道",
+	errs.CodeIncorrect:          "验证码错误，请检查后重试",
+	errs.CodeExpired:            "验证码已过期，请重新获取",
+	errs.SMSTemplateRequired:    "tplId 和 numbers 不能为空",
+	errs.ReceiptStatusUnknown:   "未知的回执状态",
+	errs.InvalidTimeRange:       "开始或结束时间无效",
+	errs.UnsupportedLocale:      "不支持的语言",
+
+	errs.Unauthorized:          "未授权",
+	errs.InvalidUserOrPassword: "用户名或密码错误",
+	errs.InvalidServiceToken:   "服务令牌无效",
+	errs.InvalidReceiptSign:    "回执签名无效",
+
+	errs.Forbidden:             "无权访问",
+	errs.SMSTemplateNotAllowed: "不允许使用该模板",
+
+	errs.UserNotFound:  "用户不存在",
+	errs.EmailConflict: "邮箱已被注册",
+
+	errs.RateLimited:              "请求过于频繁，请稍后再试",
+	errs.CodeSendTooFrequent:      "短信发送过于频繁，请稍后再试",
+	errs.CodeTooManyAttempts:      "验证码错误次数过多，请重新获取",
+	errs.PhoneHourlyQuotaExceeded: "该手机号发送验证码次数过多，请一小时后再试",
+	errs.PhoneDailyQuotaExceeded:  "该手机号今日验证码次数已达上限，请明天再试",
+	errs.IPDailyQuotaExceeded:     "您的网络今日获取验证码次数过多，请明天再试",
+	errs.BizDailyQuotaExceeded:    "短信登录繁忙，请稍后再试或使用邮箱登录",
+	errs.SMSQuotaExceeded:         "短信额度已用完",
+
+	errs.Internal: "系统错误",
+
+	errs.SMSLoginUnavailable: "短信登录暂不可用，请使用邮箱登录",
+	errs.SystemBusy:          "系统繁忙，请稍后再试",
+	errs.NotReady:            "服务未就绪",
+}
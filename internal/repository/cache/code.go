@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/cyvqet/connectify/internal/domain"
+
 	"github.com/redis/go-redis/v9"
 )
 
@@ -18,12 +20,24 @@ var (
 
 	ErrVerificationCodeSendRateLimited = errors.New("verification code send rate limited")
 
+	// ErrVerificationCodeCheckRateLimited attempts used up, the code is invalidated until a new one is sent
 	ErrVerificationCodeCheckRateLimited = errors.New("verification code check rate limited")
+
+	// ErrVerificationCodeExpired no code was sent, it expired or it was already used
+	ErrVerificationCodeExpired = errors.New("verification code expired")
 )
 
 type CodeCache interface {
-	Set(ctx context.Context, bizType, phone, verificationCode string) error
+	// Set stores a code once the resend interval of the previous one has
+	// passed. Setting the code stored again restarts its TTL and resend
+	// interval but keeps the attempts left.
+	Set(ctx context.Context, bizType, phone, verificationCode string, policy domain.CodePolicy) error
+	// Verify returns false for a wrong code, ErrVerificationCodeExpired or
+	// ErrVerificationCodeCheckRateLimited when there is no usable code.
+	// A verified code is deleted.
 	Verify(ctx context.Context, bizType, phone, verificationCode string) (bool, error)
+	// Get returns the code currently stored, or ErrKeyNotExist
+	Get(ctx context.Context, bizType, phone string) (string, error)
 }
 
 type redisCodeCache struct {
@@ -39,13 +53,16 @@ func NewCodeCache(cmd redis.Cmdable) CodeCache {
 // -2 → verification code exists but missing TTL (data exception)
 // -1 → send rate limited
 // >=0 → set successfully
-func (c *redisCodeCache) Set(ctx context.Context, bizType, phone, verificationCode string) error {
+func (c *redisCodeCache) Set(ctx context.Context, bizType, phone, verificationCode string, policy domain.CodePolicy) error {
 	key := c.buildKey(bizType, phone)
 	result, err := c.redisClient.Eval(
 		ctx,
 		luaSetCode,
 		[]string{key},
 		verificationCode,
+		int64(policy.TTL.Seconds()),
+		int64(policy.ResendInterval.Seconds()),
+		policy.MaxAttempts,
 	).Int()
 
 	if err != nil {
@@ -65,8 +82,9 @@ func (c *redisCodeCache) Set(ctx context.Context, bizType, phone, verificationCo
 	}
 }
 
-// -2 → verification code does not exist or does not match
-// -1 → verification frequency limited
+// -3 → verification code does not exist or has expired
+// -2 → verification code does not match
+// -1 → attempts used up
 // >=0 → verification passed
 func (c *redisCodeCache) Verify(ctx context.Context, bizType, phone, verificationCode string) (bool, error) {
 	result, err := c.redisClient.Eval(
@@ -81,6 +99,8 @@ func (c *redisCodeCache) Verify(ctx context.Context, bizType, phone, verificatio
 	}
 
 	switch result {
+	case -3:
+		return false, ErrVerificationCodeExpired
 	case -2:
 		return false, nil
 	case -1:
@@ -90,6 +110,15 @@ func (c *redisCodeCache) Verify(ctx context.Context, bizType, phone, verificatio
 	}
 }
 
+func (c *redisCodeCache) Get(ctx context.Context, bizType, phone string) (string, error) {
+	code, err := c.redisClient.Get(ctx, c.buildKey(bizType, phone)).Result()
+	// An empty code was invalidated after too many attempts
+	if errors.Is(err, redis.Nil) || (err == nil && code == "") {
+		return "", ErrKeyNotExist
+	}
+	return code, err
+}
+
 func (c *redisCodeCache) buildKey(bizType, phone string) string {
 	return fmt.Sprintf("phone_code:%s:%s", bizType, phone)
 }
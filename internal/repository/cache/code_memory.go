@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"context"
+	"crypto/subtle"
+	"sync"
+	"time"
+
+	"github.com/cyvqet/connectify/internal/domain"
+)
+
+// memoryCodeCache keeps codes in process memory with the same semantics as
+// redisCodeCache. Codes are not shared between instances, so it is meant for
+// local development, tests and single instance deployments.
+type memoryCodeCache struct {
+	mu        sync.Mutex
+	codes     map[string]*memoryCode
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type memoryCode struct {
+	code     string // empty once invalidated
+	attempts int    // verification attempts left
+	expireAt time.Time
+}
+
+// memorySweepInterval how often expired codes are dropped, checked lazily on Set
+const memorySweepInterval = time.Minute
+
+func NewMemoryCodeCache() CodeCache {
+	return &memoryCodeCache{
+		codes: make(map[string]*memoryCode),
+		now:   time.Now,
+	}
+}
+
+func (c *memoryCodeCache) Set(ctx context.Context, bizType, phone, verificationCode string, policy domain.CodePolicy) error {
+	key := c.buildKey(bizType, phone)
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sweep(now)
+
+	// Same rule as set_code.lua: a new code once the resend interval of the previous one has passed
+	item, ok := c.get(key, now)
+	if ok && item.expireAt.Sub(now) >= policy.TTL-policy.ResendInterval {
+		return ErrVerificationCodeSendRateLimited
+	}
+
+	attempts := policy.MaxAttempts
+	if ok && item.code == verificationCode {
+		// Sending the same code again keeps the attempts left
+		attempts = item.attempts
+	}
+	c.codes[key] = &memoryCode{
+		code:     verificationCode,
+		attempts: attempts,
+		expireAt: now.Add(policy.TTL),
+	}
+	return nil
+}
+
+func (c *memoryCodeCache) Verify(ctx context.Context, bizType, phone, verificationCode string) (bool, error) {
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := c.buildKey(bizType, phone)
+	item, ok := c.get(key, now)
+	if !ok {
+		return false, ErrVerificationCodeExpired
+	}
+	if item.code == "" || item.attempts <= 0 {
+		return false, ErrVerificationCodeCheckRateLimited
+	}
+
+	if subtle.ConstantTimeCompare([]byte(item.code), []byte(verificationCode)) == 1 {
+		// A code can only be used once
+		delete(c.codes, key)
+		return true, nil
+	}
+	item.attempts--
+	if item.attempts <= 0 {
+		// Invalidated, but kept until expiry so the resend interval still applies
+		item.code = ""
+		return false, ErrVerificationCodeCheckRateLimited
+	}
+	return false, nil
+}
+
+func (c *memoryCodeCache) Get(ctx context.Context, bizType, phone string) (string, error) {
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.get(c.buildKey(bizType, phone), now)
+	if !ok || item.code == "" {
+		return "", ErrKeyNotExist
+	}
+	return item.code, nil
+}
+
+// get returns the code under key unless it has expired, must hold c.mu
+func (c *memoryCodeCache) get(key string, now time.Time) (*memoryCode, bool) {
+	item, ok := c.codes[key]
+	if !ok {
+		return nil, false
+	}
+	if !now.Before(item.expireAt) {
+		delete(c.codes, key)
+		return nil, false
+	}
+	return item, true
+}
+
+// sweep drops expired codes at most once per memorySweepInterval, must hold c.mu
+func (c *memoryCodeCache) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < memorySweepInterval {
+		return
+	}
+	for key, item := range c.codes {
+		if !now.Before(item.expireAt) {
+			delete(c.codes, key)
+		}
+	}
+	c.lastSweep = now
+}
+
+func (c *memoryCodeCache) buildKey(bizType, phone string) string {
+	return bizType + ":" + phone
+}
@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cyvqet/connectify/internal/domain"
+)
+
+// codeCacheFactory returns a fresh cache and a function moving its clock forward
+type codeCacheFactory func(t *testing.T) (CodeCache, func(time.Duration))
+
+func TestRedisCodeCache(t *testing.T) {
+	testCodeCache(t, func(t *testing.T) (CodeCache, func(time.Duration)) {
+		mr := miniredis.RunT(t)
+		return NewCodeCache(redis.NewClient(&redis.Options{Addr: mr.Addr()})), mr.FastForward
+	})
+}
+
+func TestMemoryCodeCache(t *testing.T) {
+	testCodeCache(t, func(t *testing.T) (CodeCache, func(time.Duration)) {
+		c := NewMemoryCodeCache().(*memoryCodeCache)
+		now := time.Now()
+		c.now = func() time.Time { return now }
+		return c, func(d time.Duration) { now = now.Add(d) }
+	})
+}
+
+// testCodeCache is the behavior every CodeCache implementation must have
+func testCodeCache(t *testing.T, newCache codeCacheFactory) {
+	policy := domain.CodePolicy{
+		Length:         6,
+		Alphabet:       "0123456789",
+		TTL:            10 * time.Minute,
+		ResendInterval: time.Minute,
+		MaxAttempts:    3,
+	}
+	ctx := context.Background()
+
+	t.Run("set and get", func(t *testing.T) {
+		c, _ := newCache(t)
+		_, err := c.Get(ctx, "login", "138")
+		assert.Equal(t, ErrKeyNotExist, err)
+
+		require.NoError(t, c.Set(ctx, "login", "138", "123456", policy))
+		code, err := c.Get(ctx, "login", "138")
+		require.NoError(t, err)
+		assert.Equal(t, "123456", code)
+
+		// Keys are per biz and phone
+		_, err = c.Get(ctx, "reset", "138")
+		assert.Equal(t, ErrKeyNotExist, err)
+		_, err = c.Get(ctx, "login", "139")
+		assert.Equal(t, ErrKeyNotExist, err)
+	})
+
+	t.Run("resend window", func(t *testing.T) {
+		c, advance := newCache(t)
+		require.NoError(t, c.Set(ctx, "login", "138", "111111", policy))
+		assert.Equal(t, ErrVerificationCodeSendRateLimited, c.Set(ctx, "login", "138", "222222", policy))
+
+		advance(policy.ResendInterval)
+		assert.Equal(t, ErrVerificationCodeSendRateLimited, c.Set(ctx, "login", "138", "222222", policy))
+
+		advance(time.Second)
+		require.NoError(t, c.Set(ctx, "login", "138", "222222", policy))
+		code, err := c.Get(ctx, "login", "138")
+		require.NoError(t, err)
+		assert.Equal(t, "222222", code)
+	})
+
+	t.Run("attempts", func(t *testing.T) {
+		c, advance := newCache(t)
+		require.NoError(t, c.Set(ctx, "login", "138", "123456", policy))
+		for range policy.MaxAttempts - 1 {
+			ok, err := c.Verify(ctx, "login", "138", "000000")
+			require.NoError(t, err)
+			assert.False(t, ok)
+		}
+		// The last attempt invalidates the code
+		_, err := c.Verify(ctx, "login", "138", "000000")
+		assert.Equal(t, ErrVerificationCodeCheckRateLimited, err)
+		_, err = c.Verify(ctx, "login", "138", "123456")
+		assert.Equal(t, ErrVerificationCodeCheckRateLimited, err)
+		_, err = c.Get(ctx, "login", "138")
+		assert.Equal(t, ErrKeyNotExist, err)
+
+		// A new code still has to wait for the resend interval, then comes with fresh attempts
+		assert.Equal(t, ErrVerificationCodeSendRateLimited, c.Set(ctx, "login", "138", "654321", policy))
+		advance(policy.ResendInterval + time.Second)
+		require.NoError(t, c.Set(ctx, "login", "138", "654321", policy))
+		ok, err := c.Verify(ctx, "login", "138", "654321")
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("same code again", func(t *testing.T) {
+		c, advance := newCache(t)
+		require.NoError(t, c.Set(ctx, "login", "138", "123456", policy))
+		ok, err := c.Verify(ctx, "login", "138", "000000")
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		// Subject to the resend window like a new code
+		assert.Equal(t, ErrVerificationCodeSendRateLimited, c.Set(ctx, "login", "138", "123456", policy))
+		advance(policy.ResendInterval + time.Second)
+		require.NoError(t, c.Set(ctx, "login", "138", "123456", policy))
+
+		// The failed attempt still counts
+		for range policy.MaxAttempts - 2 {
+			ok, err := c.Verify(ctx, "login", "138", "000000")
+			require.NoError(t, err)
+			assert.False(t, ok)
+		}
+		_, err = c.Verify(ctx, "login", "138", "000000")
+		assert.Equal(t, ErrVerificationCodeCheckRateLimited, err)
+	})
+
+	t.Run("wrong length", func(t *testing.T) {
+		c, _ := newCache(t)
+		inputs := []string{"", "12345", "1234567", "123456\x00"}
+		p := policy
+		p.MaxAttempts = len(inputs) + 1
+		require.NoError(t, c.Set(ctx, "login", "138", "123456", p))
+		for _, input := range inputs {
+			ok, err := c.Verify(ctx, "login", "138", input)
+			require.NoError(t, err, input)
+			assert.False(t, ok, input)
+		}
+	})
+
+	t.Run("single use", func(t *testing.T) {
+		c, _ := newCache(t)
+		require.NoError(t, c.Set(ctx, "login", "138", "123456", policy))
+		ok, err := c.Verify(ctx, "login", "138", "123456")
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		_, err = c.Verify(ctx, "login", "138", "123456")
+		assert.Equal(t, ErrVerificationCodeExpired, err)
+		_, err = c.Get(ctx, "login", "138")
+		assert.Equal(t, ErrKeyNotExist, err)
+
+		// Used codes do not block a new send
+		require.NoError(t, c.Set(ctx, "login", "138", "654321", policy))
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		c, advance := newCache(t)
+		require.NoError(t, c.Set(ctx, "login", "138", "123456", policy))
+		advance(policy.TTL)
+
+		_, err := c.Get(ctx, "login", "138")
+		assert.Equal(t, ErrKeyNotExist, err)
+		_, err = c.Verify(ctx, "login", "138", "123456")
+		assert.Equal(t, ErrVerificationCodeExpired, err)
+		require.NoError(t, c.Set(ctx, "login", "138", "654321", policy))
+	})
+
+	t.Run("missing code", func(t *testing.T) {
+		c, _ := newCache(t)
+		_, err := c.Verify(ctx, "login", "138", "123456")
+		assert.Equal(t, ErrVerificationCodeExpired, err)
+	})
+}
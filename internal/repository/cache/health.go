@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cyvqet/connectify/pkg/logger"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrCacheUnavailable the cache is marked down by the health checker
+var ErrCacheUnavailable = errors.New("cache unavailable")
+
+// Health reports whether the cache backend should be used at all
+type Health interface {
+	Healthy() bool
+}
+
+// AlwaysHealthy is the Health of backends that do not depend on Redis,
+// e.g. the memory code cache
+type AlwaysHealthy struct{}
+
+func (AlwaysHealthy) Healthy() bool {
+	return true
+}
+
+// HealthStatus is a snapshot of the checker state
+type HealthStatus struct {
+	Healthy bool
+	// Since when the current state holds
+	Since time.Time
+	// LastError of the latest failed ping, empty once healthy again
+	LastError string
+}
+
+// RedisHealthChecker pings Redis periodically. Redis is marked down after
+// Threshold consecutive failures and up again after the first success,
+// so a single slow ping does not flip the whole service into degraded mode.
+type RedisHealthChecker struct {
+	client    redis.Cmdable
+	interval  time.Duration
+	timeout   time.Duration
+	threshold int
+	l         logger.Logger
+
+	healthy  atomic.Bool
+	mu       sync.Mutex
+	failures int
+	status   HealthStatus
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewRedisHealthChecker(client redis.Cmdable, interval, timeout time.Duration, threshold int,
+	l logger.Logger) *RedisHealthChecker {
+	h := &RedisHealthChecker{
+		client:    client,
+		interval:  interval,
+		timeout:   timeout,
+		threshold: max(threshold, 1),
+		l:         l,
+	}
+	// Optimistic until proven otherwise, the first ping follows right away
+	h.healthy.Store(true)
+	h.status = HealthStatus{Healthy: true, Since: time.Now()}
+	return h
+}
+
+// Start pings until ctx is done or Stop is called
+func (h *RedisHealthChecker) Start(ctx context.Context) {
+	ctx, h.cancel = context.WithCancel(ctx)
+	h.done = make(chan struct{})
+	go func() {
+		defer close(h.done)
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+		for {
+			h.check(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop ends the pings and waits for the one in flight, so it does not
+// fail against a client closed right after
+func (h *RedisHealthChecker) Stop() {
+	if h.cancel == nil {
+		return
+	}
+	h.cancel()
+	<-h.done
+}
+
+func (h *RedisHealthChecker) Healthy() bool {
+	return h.healthy.Load()
+}
+
+func (h *RedisHealthChecker) Status() HealthStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.status
+}
+
+func (h *RedisHealthChecker) check(ctx context.Context) {
+	pingCtx, cancel := context.WithTimeout(ctx, h.timeout)
+	err := h.client.Ping(pingCtx).Err()
+	cancel()
+	if ctx.Err() != nil {
+		// Stopped, the failure says nothing about Redis
+		return
+	}
+	h.report(err)
+}
+
+func (h *RedisHealthChecker) report(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err == nil {
+		h.failures = 0
+		if !h.status.Healthy {
+			h.l.Info("redis is healthy again, leaving degraded mode")
+			h.status = HealthStatus{Healthy: true, Since: time.Now()}
+			h.healthy.Store(true)
+		}
+		return
+	}
+
+	h.failures++
+	h.status.LastError = err.Error()
+	if h.status.Healthy && h.failures >= h.threshold {
+		h.l.Error("redis is unhealthy, entering degraded mode",
+			logger.Int("failures", h.failures), logger.Error(err))
+		h.status.Healthy = false
+		h.status.Since = time.Now()
+		h.healthy.Store(false)
+	}
+}
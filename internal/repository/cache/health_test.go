@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cyvqet/connectify/pkg/logger"
+)
+
+func TestRedisHealthChecker_Stop(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	h := NewRedisHealthChecker(client, 10*time.Millisecond, 100*time.Millisecond, 1, logger.NewNopLogger())
+
+	h.Start(context.Background())
+	time.Sleep(30 * time.Millisecond)
+	h.Stop()
+
+	// Closing the client after Stop must not flip the state, nothing pings anymore
+	assert.NoError(t, client.Close())
+	time.Sleep(30 * time.Millisecond)
+	assert.True(t, h.Healthy())
+
+	// Stopping twice or without Start is harmless
+	h.Stop()
+	NewRedisHealthChecker(client, time.Second, time.Second, 1, logger.NewNopLogger()).Stop()
+}
@@ -5,18 +5,27 @@ local key = KEYS[1]
 local cntKey = key..":cnt"
 -- The code you are preparing to store
 local val = ARGV[1]
+-- Code lifetime (seconds)
+local expire = tonumber(ARGV[2])
+-- Minimum interval between two sends (seconds)
+local interval = tonumber(ARGV[3])
+-- Verification attempts allowed
+local attempts = tonumber(ARGV[4])
 
 local ttl = tonumber(redis.call("ttl", key))
 if ttl == -1 then
     --    key exists, but no expiration time
     return -2
-elseif ttl == -2 or ttl < 540 then
+elseif ttl == -2 or ttl < expire - interval then
     --    can send verification code
+    local cur = redis.call("get", key)
     redis.call("set", key, val)
-    -- 600 seconds
-    redis.call("expire", key, 600)
-    redis.call("set", cntKey, 3)
-    redis.call("expire", cntKey, 600)
+    redis.call("expire", key, expire)
+    --    sending the same code again keeps the attempts left
+    if cur ~= val then
+        redis.call("set", cntKey, attempts)
+    end
+    redis.call("expire", cntKey, expire)
     return 0
 else
     --    send too frequently
@@ -0,0 +1,30 @@
+---@diagnostic disable: undefined-global
+
+-- KEYS: phone hourly, phone daily, ip daily, biz daily counters
+-- ARGV[1..n]: limit of each counter, 0 means unlimited
+-- ARGV[n+1..2n]: window of each counter in seconds
+local n = #KEYS
+
+-- 1. Check every layer before counting, so a rejected request consumes nothing
+for i = 1, n do
+    local limit = tonumber(ARGV[i])
+    if limit > 0 then
+        local cnt = tonumber(redis.call("get", KEYS[i]) or "0")
+        if cnt >= limit then
+            --    return which layer is exhausted (1-based)
+            return i
+        end
+    end
+end
+
+-- 2. All layers allow it, count this send
+for i = 1, n do
+    if tonumber(ARGV[i]) > 0 then
+        local cnt = redis.call("incr", KEYS[i])
+        if cnt == 1 then
+            redis.call("expire", KEYS[i], ARGV[n + i])
+        end
+    end
+end
+
+return 0
@@ -0,0 +1,18 @@
+---@diagnostic disable: undefined-global
+
+-- KEYS: phone hourly, phone daily, ip daily, biz daily counters
+-- ARGV[1..n]: limit of each counter, 0 means unlimited and not counted
+local n = #KEYS
+
+-- Give back a send counted by sms_quota.lua, never below zero. An expired
+-- counter stays missing, its window started over anyway.
+for i = 1, n do
+    if tonumber(ARGV[i]) > 0 then
+        local cnt = tonumber(redis.call("get", KEYS[i]) or "0")
+        if cnt > 0 then
+            redis.call("decr", KEYS[i])
+        end
+    end
+end
+
+return 0
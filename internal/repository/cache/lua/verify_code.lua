@@ -3,21 +3,42 @@
 local key = KEYS[1]
 local cntKey = key..":cnt"
 -- The code the user entered
-local expectedCode = ARGV[1]
+local input = ARGV[1]
 
-local cnt = tonumber(redis.call("get", cntKey))
 local code = redis.call("get", key)
+if not code then
+--    never sent or expired
+    return -3
+end
 
-if cnt == nil or cnt <= 0 then
---    verification count depleted
+local cnt = tonumber(redis.call("get", cntKey))
+if code == "" or cnt == nil or cnt <= 0 then
+--    attempts used up, the code was invalidated
     return -1
 end
 
-if code == expectedCode then
-    redis.call("set", cntKey, 0)
+-- Constant-time comparison: always walk the whole stored code
+-- and accumulate the differences instead of returning early
+local diff = 0
+if #code ~= #input then
+    diff = 1
+end
+for i = 1, #code do
+    local d = string.byte(code, i) - (string.byte(input, i) or 0)
+    diff = diff + d * d
+end
+
+if diff == 0 then
+--    a code can only be used once
+    redis.call("del", key, cntKey)
     return 0
-else
-    redis.call("decr", cntKey)
-    --    not equal, the user entered the wrong code
-    return -2
-end
\ No newline at end of file
+end
+
+cnt = redis.call("decr", cntKey)
+if cnt <= 0 then
+--    last attempt, invalidate the code but keep its ttl so the resend interval still applies
+    redis.call("set", key, "", "KEEPTTL")
+    return -1
+end
+--    not equal, the user entered the wrong code
+return -2
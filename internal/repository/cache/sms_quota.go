@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	//go:embed lua/sms_quota.lua
+	luaSMSQuota string
+
+	//go:embed lua/sms_quota_decr.lua
+	luaSMSQuotaDecr string
+
+	ErrPhoneHourlyQuotaExceeded = errors.New("phone hourly sms quota exceeded")
+	ErrPhoneDailyQuotaExceeded  = errors.New("phone daily sms quota exceeded")
+	ErrIPDailyQuotaExceeded     = errors.New("ip daily sms quota exceeded")
+	ErrBizDailyQuotaExceeded    = errors.New("biz daily sms quota exceeded")
+)
+
+// SMSQuota is the maximum number of sends per layer, 0 means unlimited
+type SMSQuota struct {
+	PhoneHourly int
+	PhoneDaily  int
+	IPDaily     int
+	BizDaily    int
+}
+
+type SMSQuotaCache interface {
+	// Incr counts one send against every layer, or returns the error of the exhausted layer
+	Incr(ctx context.Context, bizType, phone, ip string) error
+	// Decr gives back a send counted by Incr that did not happen
+	Decr(ctx context.Context, bizType, phone, ip string) error
+}
+
+type redisSMSQuotaCache struct {
+	redisClient redis.Cmdable
+	quota       SMSQuota
+}
+
+func NewSMSQuotaCache(cmd redis.Cmdable, quota SMSQuota) SMSQuotaCache {
+	return &redisSMSQuotaCache{
+		redisClient: cmd,
+		quota:       quota,
+	}
+}
+
+// 0 → allowed and counted
+// 1..4 → the layer at that position is exhausted
+func (c *redisSMSQuotaCache) Incr(ctx context.Context, bizType, phone, ip string) error {
+	day := int64((24 * time.Hour).Seconds())
+	args := append(c.limits(ip), int64(time.Hour.Seconds()), day, day, day)
+	result, err := c.redisClient.Eval(ctx, luaSMSQuota, c.keys(bizType, phone, ip), args...).Int()
+	if err != nil {
+		return err
+	}
+
+	switch result {
+	case 0:
+		return nil
+	case 1:
+		return ErrPhoneHourlyQuotaExceeded
+	case 2:
+		return ErrPhoneDailyQuotaExceeded
+	case 3:
+		return ErrIPDailyQuotaExceeded
+	case 4:
+		return ErrBizDailyQuotaExceeded
+	default:
+		return fmt.Errorf("unexpected sms quota result: %d", result)
+	}
+}
+
+func (c *redisSMSQuotaCache) Decr(ctx context.Context, bizType, phone, ip string) error {
+	return c.redisClient.Eval(ctx, luaSMSQuotaDecr, c.keys(bizType, phone, ip), c.limits(ip)...).Err()
+}
+
+func (c *redisSMSQuotaCache) keys(bizType, phone, ip string) []string {
+	return []string{
+		fmt.Sprintf("sms_quota:phone:hour:%s", phone),
+		fmt.Sprintf("sms_quota:phone:day:%s", phone),
+		fmt.Sprintf("sms_quota:ip:day:%s", ip),
+		fmt.Sprintf("sms_quota:biz:day:%s", bizType),
+	}
+}
+
+// limits of the layers in the order of keys
+func (c *redisSMSQuotaCache) limits(ip string) []any {
+	ipDaily := c.quota.IPDaily
+	if ip == "" {
+		// Unknown client ip, only the other layers apply
+		ipDaily = 0
+	}
+	return []any{c.quota.PhoneHourly, c.quota.PhoneDaily, ipDaily, c.quota.BizDaily}
+}
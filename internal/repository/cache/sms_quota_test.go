@@ -0,0 +1,188 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type quotaSend struct {
+	biz, phone, ip string
+}
+
+func newTestSMSQuotaCache(t *testing.T, quota SMSQuota) (SMSQuotaCache, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	return NewSMSQuotaCache(redis.NewClient(&redis.Options{Addr: mr.Addr()}), quota), mr
+}
+
+func TestRedisSMSQuotaCache_Incr(t *testing.T) {
+	// Same phone, ip and biz, send after send
+	same := func(n int) []quotaSend {
+		sends := make([]quotaSend, n)
+		for i := range sends {
+			sends[i] = quotaSend{"bizLogin", "13800138000", "10.0.0.1"}
+		}
+		return sends
+	}
+
+	testCases := []struct {
+		name  string
+		quota SMSQuota
+		// All allowed
+		before []quotaSend
+		send   quotaSend
+
+		wantErr error
+	}{
+		{
+			name:   "within every quota",
+			quota:  SMSQuota{PhoneHourly: 2, PhoneDaily: 5, IPDaily: 10, BizDaily: 100},
+			before: same(1),
+			send:   quotaSend{"bizLogin", "13800138000", "10.0.0.1"},
+		},
+		{
+			name:    "phone hourly",
+			quota:   SMSQuota{PhoneHourly: 2, PhoneDaily: 5, IPDaily: 10, BizDaily: 100},
+			before:  same(2),
+			send:    quotaSend{"bizLogin", "13800138000", "10.0.0.1"},
+			wantErr: ErrPhoneHourlyQuotaExceeded,
+		},
+		{
+			name:    "phone daily",
+			quota:   SMSQuota{PhoneDaily: 2, IPDaily: 10, BizDaily: 100},
+			before:  same(2),
+			send:    quotaSend{"bizLogin", "13800138000", "10.0.0.1"},
+			wantErr: ErrPhoneDailyQuotaExceeded,
+		},
+		{
+			name:  "ip daily across phones",
+			quota: SMSQuota{PhoneHourly: 1, IPDaily: 2, BizDaily: 100},
+			before: []quotaSend{
+				{"bizLogin", "13800138001", "10.0.0.1"},
+				{"bizLogin", "13800138002", "10.0.0.1"},
+			},
+			send:    quotaSend{"bizLogin", "13800138003", "10.0.0.1"},
+			wantErr: ErrIPDailyQuotaExceeded,
+		},
+		{
+			name:  "biz daily across phones and ips",
+			quota: SMSQuota{PhoneHourly: 1, IPDaily: 1, BizDaily: 2},
+			before: []quotaSend{
+				{"bizLogin", "13800138001", "10.0.0.1"},
+				{"bizLogin", "13800138002", "10.0.0.2"},
+			},
+			send:    quotaSend{"bizLogin", "13800138003", "10.0.0.3"},
+			wantErr: ErrBizDailyQuotaExceeded,
+		},
+		{
+			name:  "other biz has its own quota",
+			quota: SMSQuota{BizDaily: 2},
+			before: []quotaSend{
+				{"bizLogin", "13800138001", "10.0.0.1"},
+				{"bizLogin", "13800138002", "10.0.0.2"},
+			},
+			send: quotaSend{"bizSignup", "13800138003", "10.0.0.3"},
+		},
+		{
+			name:   "first exhausted layer reported",
+			quota:  SMSQuota{PhoneHourly: 2, PhoneDaily: 2, IPDaily: 2, BizDaily: 2},
+			before: same(2),
+			send:   quotaSend{"bizLogin", "13800138000", "10.0.0.1"},
+			// Every layer is exhausted
+			wantErr: ErrPhoneHourlyQuotaExceeded,
+		},
+		{
+			name:   "0 means unlimited",
+			quota:  SMSQuota{},
+			before: same(50),
+			send:   quotaSend{"bizLogin", "13800138000", "10.0.0.1"},
+		},
+		{
+			name:  "unknown ip skips the ip layer",
+			quota: SMSQuota{IPDaily: 1},
+			before: []quotaSend{
+				{"bizLogin", "13800138001", ""},
+			},
+			send: quotaSend{"bizLogin", "13800138002", ""},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, _ := newTestSMSQuotaCache(t, tc.quota)
+			ctx := context.Background()
+			for _, s := range tc.before {
+				require.NoError(t, c.Incr(ctx, s.biz, s.phone, s.ip))
+			}
+			err := c.Incr(ctx, tc.send.biz, tc.send.phone, tc.send.ip)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestRedisSMSQuotaCache_Keys(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("rejected send counts nothing", func(t *testing.T) {
+		c, mr := newTestSMSQuotaCache(t, SMSQuota{PhoneHourly: 1, PhoneDaily: 5, IPDaily: 5, BizDaily: 5})
+		require.NoError(t, c.Incr(ctx, "bizLogin", "13800138000", "10.0.0.1"))
+		assert.Equal(t, ErrPhoneHourlyQuotaExceeded, c.Incr(ctx, "bizLogin", "13800138000", "10.0.0.1"))
+
+		for _, key := range []string{"sms_quota:phone:day:13800138000", "sms_quota:ip:day:10.0.0.1", "sms_quota:biz:day:bizLogin"} {
+			got, err := mr.Get(key)
+			require.NoError(t, err)
+			assert.Equal(t, "1", got, key)
+		}
+	})
+
+	t.Run("unlimited layers are not counted", func(t *testing.T) {
+		c, mr := newTestSMSQuotaCache(t, SMSQuota{PhoneHourly: 5})
+		require.NoError(t, c.Incr(ctx, "bizLogin", "13800138000", "10.0.0.1"))
+		assert.Equal(t, []string{"sms_quota:phone:hour:13800138000"}, mr.Keys())
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		c, mr := newTestSMSQuotaCache(t, SMSQuota{PhoneHourly: 1, PhoneDaily: 2, IPDaily: 5, BizDaily: 5})
+		require.NoError(t, c.Incr(ctx, "bizLogin", "13800138000", "10.0.0.1"))
+		assert.Equal(t, time.Hour, mr.TTL("sms_quota:phone:hour:13800138000"))
+		for _, key := range []string{"sms_quota:phone:day:13800138000", "sms_quota:ip:day:10.0.0.1", "sms_quota:biz:day:bizLogin"} {
+			assert.Equal(t, 24*time.Hour, mr.TTL(key), key)
+		}
+
+		// A later send does not push the window out
+		mr.FastForward(time.Hour)
+		assert.False(t, mr.Exists("sms_quota:phone:hour:13800138000"))
+		require.NoError(t, c.Incr(ctx, "bizLogin", "13800138000", "10.0.0.1"))
+		assert.Equal(t, 23*time.Hour, mr.TTL("sms_quota:phone:day:13800138000"))
+
+		// The hourly window started over, the daily one is used up
+		mr.FastForward(time.Hour)
+		assert.Equal(t, ErrPhoneDailyQuotaExceeded, c.Incr(ctx, "bizLogin", "13800138000", "10.0.0.1"))
+
+		mr.FastForward(22 * time.Hour)
+		assert.NoError(t, c.Incr(ctx, "bizLogin", "13800138000", "10.0.0.1"))
+	})
+}
+
+func TestRedisSMSQuotaCache_Decr(t *testing.T) {
+	ctx := context.Background()
+	c, mr := newTestSMSQuotaCache(t, SMSQuota{PhoneHourly: 1, PhoneDaily: 5, IPDaily: 5, BizDaily: 5})
+	require.NoError(t, c.Incr(ctx, "bizLogin", "13800138000", "10.0.0.1"))
+	require.NoError(t, c.Decr(ctx, "bizLogin", "13800138000", "10.0.0.1"))
+
+	// Given back, the next send is allowed
+	require.NoError(t, c.Incr(ctx, "bizLogin", "13800138000", "10.0.0.1"))
+
+	// Never below zero, a counter that expired stays missing
+	mr.FastForward(time.Hour)
+	require.NoError(t, c.Decr(ctx, "bizLogin", "13800138000", "10.0.0.1"))
+	require.NoError(t, c.Decr(ctx, "bizLogin", "13800138000", "10.0.0.1"))
+	assert.False(t, mr.Exists("sms_quota:phone:hour:13800138000"))
+	got, err := mr.Get("sms_quota:phone:day:13800138000")
+	require.NoError(t, err)
+	assert.Equal(t, "0", got)
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math/rand/v2"
 	"time"
 
 	"github.com/cyvqet/connectify/internal/domain"
@@ -12,22 +13,41 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-var ErrKeyNotExist = redis.Nil
+var (
+	ErrKeyNotExist = redis.Nil
+	// ErrUserNotFoundCached the id was looked up recently and does not exist
+	ErrUserNotFoundCached = errors.New("user not found, cached")
+)
+
+// notFoundValue marks an id known not to exist, a marshaled user is never empty
+const notFoundValue = ""
 
 type UserCache interface {
 	Get(ctx context.Context, id int64) (domain.User, error)
 	Set(ctx context.Context, user domain.User) error
+	// SetNotFound remembers for a short time that id does not exist,
+	// so repeated lookups of missing ids do not all reach the database
+	SetNotFound(ctx context.Context, id int64) error
+	Del(ctx context.Context, id int64) error
+}
+
+type UserCacheConfig struct {
+	Expire time.Duration // base ttl of a user
+	Jitter time.Duration // random extra ttl in [0, Jitter), spreads out expirations
+	// ttl of not-found entries, 0 disables negative caching.
+	// Keep it short: an id cached as missing stays missing this long after it is created.
+	NotFoundExpire time.Duration
 }
 
 type redisUserCache struct {
 	client redis.Cmdable
-	expire time.Duration
+	cfg    UserCacheConfig
 }
 
-func NewUserCache(client redis.Cmdable) UserCache {
+func NewUserCache(client redis.Cmdable, cfg UserCacheConfig) UserCache {
 	return &redisUserCache{
 		client: client,
-		expire: time.Minute * 10,
+		cfg:    cfg,
 	}
 }
 
@@ -40,6 +60,9 @@ func (c *redisUserCache) Get(ctx context.Context, id int64) (domain.User, error)
 	if err != nil {
 		return domain.User{}, err
 	}
+	if data == notFoundValue {
+		return domain.User{}, ErrUserNotFoundCached
+	}
 
 	var user domain.User
 	if err := json.Unmarshal([]byte(data), &user); err != nil {
@@ -54,7 +77,26 @@ func (c *redisUserCache) Set(ctx context.Context, user domain.User) error {
 	if err != nil {
 		return err
 	}
-	return c.client.Set(ctx, c.key(user.Id), data, c.expire).Err()
+	return c.client.Set(ctx, c.key(user.Id), data, c.expire()).Err()
+}
+
+func (c *redisUserCache) SetNotFound(ctx context.Context, id int64) error {
+	if c.cfg.NotFoundExpire <= 0 {
+		return nil
+	}
+	return c.client.Set(ctx, c.key(id), notFoundValue, c.cfg.NotFoundExpire).Err()
+}
+
+func (c *redisUserCache) Del(ctx context.Context, id int64) error {
+	return c.client.Del(ctx, c.key(id)).Err()
+}
+
+// expire adds random jitter so users cached together do not expire together
+func (c *redisUserCache) expire() time.Duration {
+	if c.cfg.Jitter <= 0 {
+		return c.cfg.Expire
+	}
+	return c.cfg.Expire + rand.N(c.cfg.Jitter)
 }
 
 func (c *redisUserCache) key(id int64) string {
@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cyvqet/connectify/internal/domain"
+	"github.com/cyvqet/connectify/pkg/prometheusx"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricsUserCache counts the results of UserCache reads
+type metricsUserCache struct {
+	UserCache
+	gets *prometheus.CounterVec
+}
+
+// NewMetricsUserCache registers the counter with reg
+func NewMetricsUserCache(c UserCache, reg prometheus.Registerer) UserCache {
+	return &metricsUserCache{
+		UserCache: c,
+		gets: prometheusx.MustRegister(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "connectify",
+			Subsystem: "cache",
+			Name:      "user_gets_total",
+			Help:      "User cache reads by result: hit, miss, not_found (cached missing user) or error",
+		}, []string{"result"})),
+	}
+}
+
+func (c *metricsUserCache) Get(ctx context.Context, id int64) (domain.User, error) {
+	user, err := c.UserCache.Get(ctx, id)
+	result := "error"
+	switch {
+	case err == nil:
+		result = "hit"
+	case errors.Is(err, ErrKeyNotExist):
+		result = "miss"
+	case errors.Is(err, ErrUserNotFoundCached):
+		result = "not_found"
+	}
+	c.gets.WithLabelValues(result).Inc()
+	return user, err
+}
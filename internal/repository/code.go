@@ -3,33 +3,54 @@ package repository
 import (
 	"context"
 
+	"github.com/cyvqet/connectify/internal/domain"
 	"github.com/cyvqet/connectify/internal/repository/cache"
 )
 
 var (
 	ErrVerificationCodeSendRateLimited  = cache.ErrVerificationCodeSendRateLimited
 	ErrVerificationCodeCheckRateLimited = cache.ErrVerificationCodeCheckRateLimited
+	ErrVerificationCodeExpired          = cache.ErrVerificationCodeExpired
+	ErrCodeNotExist                     = cache.ErrKeyNotExist
+	// ErrCodeUnavailable codes live only in the cache, so nothing works while it is down
+	ErrCodeUnavailable = cache.ErrCacheUnavailable
 )
 
 type CodeRepository interface {
-	Set(ctx context.Context, bizType, phone, verificationCode string) error
+	Set(ctx context.Context, bizType, phone, verificationCode string, policy domain.CodePolicy) error
 	Verify(ctx context.Context, bizType, phone, verificationCode string) (bool, error)
+	Get(ctx context.Context, bizType, phone string) (string, error)
 }
 
 type codeRepository struct {
-	cache cache.CodeCache
+	cache  cache.CodeCache
+	health cache.Health
 }
 
-func NewCodeRepository(codeCache cache.CodeCache) CodeRepository {
+func NewCodeRepository(codeCache cache.CodeCache, health cache.Health) CodeRepository {
 	return &codeRepository{
-		cache: codeCache,
+		cache:  codeCache,
+		health: health,
 	}
 }
 
-func (r *codeRepository) Set(ctx context.Context, bizType, phone, verificationCode string) error {
-	return r.cache.Set(ctx, bizType, phone, verificationCode)
+func (r *codeRepository) Set(ctx context.Context, bizType, phone, verificationCode string, policy domain.CodePolicy) error {
+	if !r.health.Healthy() {
+		return ErrCodeUnavailable
+	}
+	return r.cache.Set(ctx, bizType, phone, verificationCode, policy)
 }
 
 func (r *codeRepository) Verify(ctx context.Context, bizType, phone, verificationCode string) (bool, error) {
+	if !r.health.Healthy() {
+		return false, ErrCodeUnavailable
+	}
 	return r.cache.Verify(ctx, bizType, phone, verificationCode)
 }
+
+func (r *codeRepository) Get(ctx context.Context, bizType, phone string) (string, error) {
+	if !r.health.Healthy() {
+		return "", ErrCodeUnavailable
+	}
+	return r.cache.Get(ctx, bizType, phone)
+}
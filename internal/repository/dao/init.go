@@ -1,7 +0,0 @@
-package dao
-
-import "gorm.io/gorm"
-
-func InitTables(db *gorm.DB) error {
-	return db.AutoMigrate(&User{})
-}
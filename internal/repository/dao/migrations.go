@@ -0,0 +1,10 @@
+package dao
+
+import "embed"
+
+// Migrations hold the schema as <version>_<name>.up.sql and .down.sql pairs,
+// applied by pkg/migrate. A model change comes with a new pair, never an edit
+// of one already released.
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
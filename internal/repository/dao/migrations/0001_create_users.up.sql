@@ -0,0 +1,13 @@
+-- IF NOT EXISTS keeps databases created by the former AutoMigrate as they are
+CREATE TABLE IF NOT EXISTS users (
+    id         BIGINT       NOT NULL AUTO_INCREMENT,
+    email      VARCHAR(191) NULL,
+    phone      VARCHAR(191) NULL,
+    password   LONGTEXT     NULL,
+    locale     VARCHAR(16)  NULL,
+    created_at BIGINT       NULL,
+    updated_at BIGINT       NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT uni_users_email UNIQUE (email),
+    CONSTRAINT uni_users_phone UNIQUE (phone)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS sms_logs (
+    id              BIGINT           NOT NULL AUTO_INCREMENT,
+    phone_hash      CHAR(64)         NULL,
+    phone           VARCHAR(32)      NULL,
+    tpl_id          VARCHAR(128)     NULL,
+    provider        VARCHAR(32)      NULL,
+    provider_msg_id VARCHAR(128)     NULL,
+    latency_ms      BIGINT           NULL,
+    status          TINYINT UNSIGNED NULL,
+    err_msg         VARCHAR(512)     NULL,
+    created_at      BIGINT           NULL,
+    updated_at      BIGINT           NULL,
+    PRIMARY KEY (id),
+    INDEX idx_phone_ctime (phone_hash, created_at),
+    INDEX idx_provider_msg (provider, provider_msg_id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
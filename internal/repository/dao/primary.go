@@ -0,0 +1,26 @@
+package dao
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+type primaryKey struct{}
+
+// WithPrimary sends the reads made with ctx to the primary, for reads that
+// must see a write just made and cannot wait for the replicas to catch up
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// dbFor is db bound to ctx, reading from the primary when ctx asks for it.
+// Without replicas configured every statement goes to the primary anyway.
+func dbFor(ctx context.Context, db *gorm.DB) *gorm.DB {
+	db = db.WithContext(ctx)
+	if primary, _ := ctx.Value(primaryKey{}).(bool); primary {
+		return db.Clauses(dbresolver.Write)
+	}
+	return db
+}
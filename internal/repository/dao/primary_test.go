@@ -0,0 +1,57 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+func TestWithPrimary(t *testing.T) {
+	dir := t.TempDir()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "primary.db")))
+	require.NoError(t, err)
+	replica := sqlite.Open(filepath.Join(dir, "replica.db"))
+	require.NoError(t, db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: []gorm.Dialector{replica},
+	})))
+
+	// Both have the table, the replica lags behind and misses the user
+	require.NoError(t, db.AutoMigrate(&User{}))
+	replicaDB, err := gorm.Open(replica)
+	require.NoError(t, err)
+	require.NoError(t, replicaDB.AutoMigrate(&User{}))
+
+	d := NewUserDao(db)
+	ctx := context.Background()
+	_, err = d.Insert(ctx, User{Phone: sql.NullString{String: "13800138000", Valid: true}})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		ctx     context.Context
+		wantErr error
+	}{
+		{
+			name:    "replica",
+			ctx:     ctx,
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name: "primary",
+			ctx:  WithPrimary(ctx),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := d.FindById(tc.ctx, 1)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
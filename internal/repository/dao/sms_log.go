@@ -0,0 +1,67 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SMSLog struct {
+	Id            int64  `gorm:"primaryKey,autoIncrement"`
+	PhoneHash     string `gorm:"type:char(64);index:idx_phone_ctime,priority:1"`
+	Phone         string `gorm:"type:varchar(32)"` // masked
+	TplId         string `gorm:"type:varchar(128)"`
+	Provider      string `gorm:"type:varchar(32);index:idx_provider_msg,priority:1"`
+	ProviderMsgId string `gorm:"type:varchar(128);index:idx_provider_msg,priority:2"`
+	LatencyMs     int64
+	Status        uint8
+	ErrMsg        string `gorm:"type:varchar(512)"`
+	CreatedAt     int64  `gorm:"index:idx_phone_ctime,priority:2"`
+	UpdatedAt     int64
+}
+
+type SMSLogDao interface {
+	Insert(ctx context.Context, logs []SMSLog) error
+	UpdateStatus(ctx context.Context, provider, msgId string, status uint8, errMsg string) error
+	FindByPhone(ctx context.Context, phoneHash string, start, end int64, offset, limit int) ([]SMSLog, error)
+}
+
+type gormSMSLogDao struct {
+	db *gorm.DB
+}
+
+func NewSMSLogDao(db *gorm.DB) SMSLogDao {
+	return &gormSMSLogDao{
+		db: db,
+	}
+}
+
+func (dao *gormSMSLogDao) Insert(ctx context.Context, logs []SMSLog) error {
+	now := time.Now().UnixMilli()
+	for i := range logs {
+		logs[i].CreatedAt = now
+		logs[i].UpdatedAt = now
+	}
+	return dao.db.WithContext(ctx).Create(&logs).Error
+}
+
+func (dao *gormSMSLogDao) UpdateStatus(ctx context.Context, provider, msgId string, status uint8, errMsg string) error {
+	return dao.db.WithContext(ctx).Model(&SMSLog{}).
+		Where("provider=? AND provider_msg_id=?", provider, msgId).
+		Updates(map[string]any{
+			"status":     status,
+			"err_msg":    errMsg,
+			"updated_at": time.Now().UnixMilli(),
+		}).Error
+}
+
+func (dao *gormSMSLogDao) FindByPhone(ctx context.Context, phoneHash string, start, end int64, offset, limit int) ([]SMSLog, error) {
+	var logs []SMSLog
+	err := dao.db.WithContext(ctx).
+		Where("phone_hash=? AND created_at>=? AND created_at<?", phoneHash, start, end).
+		Order("created_at DESC").
+		Offset(offset).Limit(limit).
+		Find(&logs).Error
+	return logs, err
+}
@@ -20,15 +20,18 @@ type User struct {
 	Email     sql.NullString `gorm:"unique"`
 	Phone     sql.NullString `gorm:"unique"`
 	Password  string
+	Locale    string `gorm:"type:varchar(16)"`
 	CreatedAt int64
 	UpdatedAt int64
 }
 
 type UserDao interface {
-	Insert(ctx context.Context, user User) error
+	// Insert returns the id of the new user
+	Insert(ctx context.Context, user User) (int64, error)
 	FindByEmail(ctx context.Context, email string) (User, error)
 	FindById(ctx context.Context, id int64) (User, error)
 	FindByPhone(ctx context.Context, phone string) (User, error)
+	UpdateLocale(ctx context.Context, id int64, locale string) error
 }
 
 type gormUserDao struct {
@@ -41,7 +44,7 @@ func NewUserDao(db *gorm.DB) UserDao {
 	}
 }
 
-func (dao *gormUserDao) Insert(ctx context.Context, user User) error {
+func (dao *gormUserDao) Insert(ctx context.Context, user User) (int64, error) {
 	now := time.Now().UnixMilli()
 	user.CreatedAt = now
 	user.UpdatedAt = now
@@ -49,16 +52,16 @@ func (dao *gormUserDao) Insert(ctx context.Context, user User) error {
 	err := dao.db.WithContext(ctx).Create(&user).Error
 	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
 		if mysqlErr.Number == 1062 {
-			return ErrUserDuplicateEmail // email conflict
+			return 0, ErrUserDuplicateEmail // email conflict
 		}
 	}
 
-	return err
+	return user.Id, err
 }
 
 func (dao *gormUserDao) FindByEmail(ctx context.Context, email string) (User, error) {
 	var user User
-	err := dao.db.WithContext(ctx).Where("email=?", email).First(&user).Error
+	err := dbFor(ctx, dao.db).Where("email=?", email).First(&user).Error
 	if err == gorm.ErrRecordNotFound {
 		return User{}, ErrUserNotFound
 	}
@@ -67,7 +70,7 @@ func (dao *gormUserDao) FindByEmail(ctx context.Context, email string) (User, er
 
 func (dao *gormUserDao) FindById(ctx context.Context, id int64) (User, error) {
 	var user User
-	err := dao.db.WithContext(ctx).Where("id=?", id).First(&user).Error
+	err := dbFor(ctx, dao.db).Where("id=?", id).First(&user).Error
 	if err == gorm.ErrRecordNotFound {
 		return User{}, ErrUserNotFound
 	}
@@ -76,9 +79,16 @@ func (dao *gormUserDao) FindById(ctx context.Context, id int64) (User, error) {
 
 func (dao *gormUserDao) FindByPhone(ctx context.Context, phone string) (User, error) {
 	var user User
-	err := dao.db.WithContext(ctx).Where("phone=?", phone).First(&user).Error
+	err := dbFor(ctx, dao.db).Where("phone=?", phone).First(&user).Error
 	if err == gorm.ErrRecordNotFound {
 		return User{}, ErrUserNotFound
 	}
-	return user, nil
+	return user, err
+}
+
+func (dao *gormUserDao) UpdateLocale(ctx context.Context, id int64, locale string) error {
+	return dao.db.WithContext(ctx).Model(&User{}).Where("id=?", id).Updates(map[string]any{
+		"locale":     locale,
+		"updated_at": time.Now().UnixMilli(),
+	}).Error
 }
@@ -0,0 +1,64 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestUserDao_FindByPhone(t *testing.T) {
+	ctx := context.Background()
+	phone := sql.NullString{String: "13800138000", Valid: true}
+
+	testCases := []struct {
+		name    string
+		migrate bool
+		insert  bool
+		wantErr string
+	}{
+		{
+			name:    "found",
+			migrate: true,
+			insert:  true,
+		},
+		{
+			name:    "not found",
+			migrate: true,
+			wantErr: ErrUserNotFound.Error(),
+		},
+		{
+			// Any other failure reaches the caller instead of an empty user
+			name:    "query failed",
+			wantErr: "no such table",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "user.db")))
+			require.NoError(t, err)
+			d := NewUserDao(db)
+			if tc.migrate {
+				require.NoError(t, db.AutoMigrate(&User{}))
+			}
+			if tc.insert {
+				_, err := d.Insert(ctx, User{Phone: phone})
+				require.NoError(t, err)
+			}
+
+			user, err := d.FindByPhone(ctx, phone.String)
+			if tc.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, phone, user.Phone)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/cyvqet/connectify/internal/domain"
+	"github.com/cyvqet/connectify/internal/repository/dao"
+)
+
+type SMSLogRepository interface {
+	Create(ctx context.Context, logs []domain.SMSLog) error
+	UpdateStatus(ctx context.Context, provider, msgId string, status domain.SMSStatus, errMsg string) error
+	FindByPhone(ctx context.Context, phone string, start, end time.Time, offset, limit int) ([]domain.SMSLog, error)
+}
+
+type smsLogRepository struct {
+	dao dao.SMSLogDao
+}
+
+func NewSMSLogRepository(dao dao.SMSLogDao) SMSLogRepository {
+	return &smsLogRepository{
+		dao: dao,
+	}
+}
+
+// Create stores only the masked number and a hash of the raw number for lookups
+func (r *smsLogRepository) Create(ctx context.Context, logs []domain.SMSLog) error {
+	entities := make([]dao.SMSLog, 0, len(logs))
+	for _, l := range logs {
+		entities = append(entities, dao.SMSLog{
+			PhoneHash:     r.hashPhone(l.Phone),
+			Phone:         r.maskPhone(l.Phone),
+			TplId:         l.TplId,
+			Provider:      l.Provider,
+			ProviderMsgId: l.ProviderMsgId,
+			LatencyMs:     l.Latency.Milliseconds(),
+			Status:        uint8(l.Status),
+			ErrMsg:        l.ErrMsg,
+		})
+	}
+	return r.dao.Insert(ctx, entities)
+}
+
+func (r *smsLogRepository) UpdateStatus(ctx context.Context, provider, msgId string, status domain.SMSStatus, errMsg string) error {
+	return r.dao.UpdateStatus(ctx, provider, msgId, uint8(status), errMsg)
+}
+
+func (r *smsLogRepository) FindByPhone(ctx context.Context, phone string, start, end time.Time, offset, limit int) ([]domain.SMSLog, error) {
+	logs, err := r.dao.FindByPhone(ctx, r.hashPhone(phone), start.UnixMilli(), end.UnixMilli(), offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.SMSLog, 0, len(logs))
+	for _, l := range logs {
+		res = append(res, r.entityToDomain(l))
+	}
+	return res, nil
+}
+
+func (r *smsLogRepository) entityToDomain(l dao.SMSLog) domain.SMSLog {
+	return domain.SMSLog{
+		Id:            l.Id,
+		Phone:         l.Phone,
+		TplId:         l.TplId,
+		Provider:      l.Provider,
+		ProviderMsgId: l.ProviderMsgId,
+		Latency:       time.Duration(l.LatencyMs) * time.Millisecond,
+		Status:        domain.SMSStatus(l.Status),
+		ErrMsg:        l.ErrMsg,
+		Ctime:         time.UnixMilli(l.CreatedAt),
+		Utime:         time.UnixMilli(l.UpdatedAt),
+	}
+}
+
+func (r *smsLogRepository) hashPhone(phone string) string {
+	sum := sha256.Sum256([]byte(phone))
+	return hex.EncodeToString(sum[:])
+}
+
+// maskPhone keeps the first 3 and last 4 digits, e.g. 138****5678
+func (r *smsLogRepository) maskPhone(phone string) string {
+	if len(phone) < 8 {
+		return strings.Repeat("*", len(phone))
+	}
+	return phone[:3] + strings.Repeat("*", len(phone)-7) + phone[len(phone)-4:]
+}
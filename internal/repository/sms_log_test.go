@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/cyvqet/connectify/internal/domain"
+	"github.com/cyvqet/connectify/internal/repository/dao"
+)
+
+func newTestSMSLogRepository(t *testing.T) (SMSLogRepository, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "sms_log.db")))
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&dao.SMSLog{}))
+	return NewSMSLogRepository(dao.NewSMSLogDao(db)), db
+}
+
+func TestSMSLogRepository_Create(t *testing.T) {
+	repo, db := newTestSMSLogRepository(t)
+	ctx := context.Background()
+	require.NoError(t, repo.Create(ctx, []domain.SMSLog{
+		{Phone: "13800135678", TplId: "tpl-1", Provider: "tencent", ProviderMsgId: "msg-1",
+			Latency: 120 * time.Millisecond, Status: domain.SMSStatusSent},
+	}))
+
+	// Only the masked number is stored, the raw one is hashed for lookups
+	var rows []dao.SMSLog
+	require.NoError(t, db.Find(&rows).Error)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "138****5678", rows[0].Phone)
+	assert.NotContains(t, rows[0].PhoneHash, "13800135678")
+	assert.Equal(t, int64(120), rows[0].LatencyMs)
+	assert.NotZero(t, rows[0].CreatedAt)
+}
+
+func TestSMSLogRepository_UpdateStatus(t *testing.T) {
+	testCases := []struct {
+		name     string
+		provider string
+		msgId    string
+
+		// Status of each stored log afterwards
+		want []domain.SMSStatus
+	}{
+		{
+			name:     "matching receipt",
+			provider: "tencent",
+			msgId:    "msg-1",
+			want:     []domain.SMSStatus{domain.SMSStatusDelivered, domain.SMSStatusSent},
+		},
+		{
+			// Message ids are only unique per provider
+			name:     "same msg id from another provider",
+			provider: "aliyun",
+			msgId:    "msg-1",
+			want:     []domain.SMSStatus{domain.SMSStatusSent, domain.SMSStatusSent},
+		},
+		{
+			// Not an error, e.g. a message sent before it was audited
+			name:     "unknown msg id",
+			provider: "tencent",
+			msgId:    "msg-unknown",
+			want:     []domain.SMSStatus{domain.SMSStatusSent, domain.SMSStatusSent},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, _ := newTestSMSLogRepository(t)
+			ctx := context.Background()
+			require.NoError(t, repo.Create(ctx, []domain.SMSLog{
+				{Phone: "13800135678", Provider: "tencent", ProviderMsgId: "msg-1", Status: domain.SMSStatusSent},
+				{Phone: "13800135679", Provider: "tencent", ProviderMsgId: "msg-2", Status: domain.SMSStatusSent},
+			}))
+
+			err := repo.UpdateStatus(ctx, tc.provider, tc.msgId, domain.SMSStatusDelivered, "")
+			require.NoError(t, err)
+
+			var got []domain.SMSStatus
+			for _, phone := range []string{"13800135678", "13800135679"} {
+				logs, err := repo.FindByPhone(ctx, phone, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), 0, 10)
+				require.NoError(t, err)
+				require.Len(t, logs, 1)
+				got = append(got, logs[0].Status)
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestSMSLogRepository_FindByPhone(t *testing.T) {
+	repo, db := newTestSMSLogRepository(t)
+	ctx := context.Background()
+	base := time.UnixMilli(1_700_000_000_000)
+	// Three logs of the phone an hour apart, one of another phone
+	for i, phone := range []string{"13800135678", "13800135678", "13800135678", "13900000000"} {
+		require.NoError(t, repo.Create(ctx, []domain.SMSLog{{Phone: phone, TplId: string(rune('a' + i))}}))
+	}
+	for i := 1; i <= 4; i++ {
+		require.NoError(t, db.Model(&dao.SMSLog{}).Where("id=?", i).
+			Update("created_at", base.Add(time.Duration(i)*time.Hour).UnixMilli()).Error)
+	}
+
+	testCases := []struct {
+		name          string
+		phone         string
+		start, end    time.Time
+		offset, limit int
+
+		wantTplIds []string
+	}{
+		{
+			name:       "newest first",
+			phone:      "13800135678",
+			start:      base,
+			end:        base.Add(24 * time.Hour),
+			limit:      10,
+			wantTplIds: []string{"c", "b", "a"},
+		},
+		{
+			name:       "start included, end excluded",
+			phone:      "13800135678",
+			start:      base.Add(time.Hour),
+			end:        base.Add(3 * time.Hour),
+			limit:      10,
+			wantTplIds: []string{"b", "a"},
+		},
+		{
+			name:       "paged",
+			phone:      "13800135678",
+			start:      base,
+			end:        base.Add(24 * time.Hour),
+			offset:     1,
+			limit:      1,
+			wantTplIds: []string{"b"},
+		},
+		{
+			name:       "another phone",
+			phone:      "13900000000",
+			start:      base,
+			end:        base.Add(24 * time.Hour),
+			limit:      10,
+			wantTplIds: []string{"d"},
+		},
+		{
+			name:  "no logs",
+			phone: "13700000000",
+			start: base,
+			end:   base.Add(24 * time.Hour),
+			limit: 10,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			logs, err := repo.FindByPhone(ctx, tc.phone, tc.start, tc.end, tc.offset, tc.limit)
+			require.NoError(t, err)
+			var tplIds []string
+			for _, l := range logs {
+				tplIds = append(tplIds, l.TplId)
+			}
+			assert.Equal(t, tc.wantTplIds, tplIds)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/cyvqet/connectify/internal/repository/cache"
+)
+
+var (
+	ErrPhoneHourlyQuotaExceeded = cache.ErrPhoneHourlyQuotaExceeded
+	ErrPhoneDailyQuotaExceeded  = cache.ErrPhoneDailyQuotaExceeded
+	ErrIPDailyQuotaExceeded     = cache.ErrIPDailyQuotaExceeded
+	ErrBizDailyQuotaExceeded    = cache.ErrBizDailyQuotaExceeded
+)
+
+type SMSQuotaRepository interface {
+	Incr(ctx context.Context, bizType, phone, ip string) error
+	// Decr gives back a send counted by Incr that did not happen
+	Decr(ctx context.Context, bizType, phone, ip string) error
+}
+
+// smsQuotaRepository quotas live in Redis whatever code.cache says,
+// so no code is sent while Redis is down
+type smsQuotaRepository struct {
+	cache  cache.SMSQuotaCache
+	health cache.Health
+}
+
+func NewSMSQuotaRepository(quotaCache cache.SMSQuotaCache, health cache.Health) SMSQuotaRepository {
+	return &smsQuotaRepository{
+		cache:  quotaCache,
+		health: health,
+	}
+}
+
+func (r *smsQuotaRepository) Incr(ctx context.Context, bizType, phone, ip string) error {
+	if !r.health.Healthy() {
+		return ErrCodeUnavailable
+	}
+	return r.cache.Incr(ctx, bizType, phone, ip)
+}
+
+func (r *smsQuotaRepository) Decr(ctx context.Context, bizType, phone, ip string) error {
+	if !r.health.Healthy() {
+		return ErrCodeUnavailable
+	}
+	return r.cache.Decr(ctx, bizType, phone, ip)
+}
@@ -3,44 +3,89 @@ package repository
 import (
 	"context"
 	"database/sql"
-	"fmt"
-	"log"
+	"errors"
+	"strconv"
 
 	"github.com/cyvqet/connectify/internal/domain"
 	"github.com/cyvqet/connectify/internal/repository/cache"
 	"github.com/cyvqet/connectify/internal/repository/dao"
+	"github.com/cyvqet/connectify/pkg/logger"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/semaphore"
+	"golang.org/x/sync/singleflight"
 )
 
+var tracer = otel.Tracer("github.com/cyvqet/connectify/internal/repository")
+
 var (
 	ErrUserDuplicateEmail = dao.ErrUserDuplicateEmail
 	ErrUserNotFound       = dao.ErrUserNotFound
+	// ErrUserReadBusy too many reads are already hitting the database while the cache is down
+	ErrUserReadBusy = errors.New("too many user reads while cache is degraded")
 )
 
+// WithPrimary makes the reads with ctx see the writes just made, by reading
+// from the primary instead of a replica
+func WithPrimary(ctx context.Context) context.Context {
+	return dao.WithPrimary(ctx)
+}
+
 type UserRepository interface {
 	Create(ctx context.Context, user domain.User) error
 	FindByEmail(ctx context.Context, email string) (domain.User, error)
 	FindById(ctx context.Context, id int64) (domain.User, error)
 	FindByPhone(ctx context.Context, phone string) (domain.User, error)
+	UpdateLocale(ctx context.Context, id int64, locale string) error
 }
 
 type userRepository struct {
 	dao   dao.UserDao
 	cache cache.UserCache
+	// Coalesces concurrent cache misses of the same id into one database query
+	sfg singleflight.Group
+
+	health cache.Health
+	// Bounds the reads that go straight to the database while the cache is down
+	degradedReads *semaphore.Weighted
+	l             logger.Logger
 }
 
-func NewUserRepository(dao dao.UserDao, cache cache.UserCache) UserRepository {
+// NewUserRepository maxDegradedReads limits concurrent database reads by id
+// while the cache is unhealthy, the rest fail fast with ErrUserReadBusy
+func NewUserRepository(dao dao.UserDao, cache cache.UserCache, health cache.Health,
+	maxDegradedReads int64, l logger.Logger) UserRepository {
 	return &userRepository{
-		dao:   dao,
-		cache: cache,
+		dao:           dao,
+		cache:         cache,
+		health:        health,
+		degradedReads: semaphore.NewWeighted(maxDegradedReads),
+		l:             l,
 	}
 }
 
 func (r *userRepository) Create(ctx context.Context, user domain.User) error {
-	return r.dao.Insert(ctx, dao.User{
+	id, err := r.dao.Insert(ctx, dao.User{
 		Phone:    sql.NullString{String: user.Phone, Valid: user.Phone != ""},
 		Email:    sql.NullString{String: user.Email, Valid: user.Email != ""},
 		Password: user.Password,
+		Locale:   user.Locale,
 	})
+	if err != nil {
+		return err
+	}
+	if !r.health.Healthy() {
+		return nil
+	}
+	// A lookup before the insert may have cached the id as not found
+	if err := r.cache.Del(ctx, id); err != nil {
+		r.l.WithContext(ctx).Warn("delete user from cache failed",
+			logger.Int64("userId", id), logger.Error(err))
+	}
+	return nil
 }
 
 func (r *userRepository) FindByEmail(ctx context.Context, email string) (domain.User, error) {
@@ -52,34 +97,101 @@ func (r *userRepository) FindByEmail(ctx context.Context, email string) (domain.
 	return r.entityToDomain(user), nil
 }
 
-func (r *userRepository) FindById(ctx context.Context, id int64) (domain.User, error) {
+func (r *userRepository) FindById(ctx context.Context, id int64) (user domain.User, err error) {
+	ctx, span := tracer.Start(ctx, "UserRepository.FindById",
+		trace.WithAttributes(attribute.Int64("user.id", id)))
+	defer func() {
+		if err != nil && !errors.Is(err, ErrUserNotFound) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	if !r.health.Healthy() {
+		span.SetAttributes(attribute.String("cache.result", "degraded"))
+		return r.findByIdDegraded(ctx, id)
+	}
+
 	// Get from cache first
-	user, err := r.cache.Get(ctx, id)
+	user, err = r.cache.Get(ctx, id)
 	if err == nil {
-		fmt.Println("cache hit, get from cache")
 		// cache hit
+		span.SetAttributes(attribute.String("cache.result", "hit"))
 		return user, nil
 	}
+	if errors.Is(err, cache.ErrUserNotFoundCached) {
+		span.SetAttributes(attribute.String("cache.result", "not_found"))
+		return domain.User{}, ErrUserNotFound
+	}
 
-	// Only query the database if the cache is not hit, other errors are returned directly
-	// If you want to downgrade (also query the database if the cache is out of error), you can remove this judgment
+	// The health checker has not noticed the failure yet, read like in degraded mode
 	if err != cache.ErrKeyNotExist {
-		return domain.User{}, err
+		r.l.WithContext(ctx).Warn("read user from cache failed",
+			logger.Int64("userId", id), logger.Error(err))
+		span.SetAttributes(attribute.String("cache.result", "error"))
+		return r.findByIdDegraded(ctx, id)
 	}
 
-	fmt.Println("cache miss, get from database")
+	span.SetAttributes(attribute.String("cache.result", "miss"))
+	return r.loadByIdShared(ctx, id)
+}
 
-	// Cache miss, get from database
+// findByIdDegraded reads from the database only, within the degraded read limit
+func (r *userRepository) findByIdDegraded(ctx context.Context, id int64) (domain.User, error) {
+	if !r.degradedReads.TryAcquire(1) {
+		return domain.User{}, ErrUserReadBusy
+	}
+	defer r.degradedReads.Release(1)
+	return r.loadByIdShared(ctx, id)
+}
+
+// loadByIdShared get from database. Concurrent loads of an id share one query,
+// which must not be canceled just because the first caller went away.
+func (r *userRepository) loadByIdShared(ctx context.Context, id int64) (domain.User, error) {
+	val, err, _ := r.sfg.Do(strconv.FormatInt(id, 10), func() (any, error) {
+		return r.loadById(context.WithoutCancel(ctx), id)
+	})
+	if err != nil {
+		return domain.User{}, err
+	}
+	return val.(domain.User), nil
+}
+
+func (r *userRepository) loadById(ctx context.Context, id int64) (domain.User, error) {
+	// Cache writes are skipped while the cache is down
+	writeBack := r.health.Healthy()
+	if writeBack {
+		// What is cached stays for the whole expiry, a lagging replica could
+		// bring back the row just updated or miss the user just created
+		ctx = dao.WithPrimary(ctx)
+	}
 	u, err := r.dao.FindById(ctx, id)
+	if err == dao.ErrUserNotFound {
+		if !writeBack {
+			return domain.User{}, ErrUserNotFound
+		}
+		// Cache the miss too, otherwise nonexistent ids always reach the database
+		if err := r.cache.SetNotFound(ctx, id); err != nil {
+			r.l.WithContext(ctx).Warn("write not found to cache failed",
+				logger.Int64("userId", id), logger.Error(err))
+		}
+		return domain.User{}, ErrUserNotFound
+	}
 	if err != nil {
 		return domain.User{}, err
 	}
 
-	user = r.entityToDomain(u)
+	user := r.entityToDomain(u)
+
+	if !writeBack {
+		return user, nil
+	}
 
 	// Write back to cache, only log if failed
 	if err := r.cache.Set(ctx, user); err != nil {
-		log.Printf("WARN: write back to cache failed, userId: %d, err: %v", user.Id, err)
+		r.l.WithContext(ctx).Warn("write back to cache failed",
+			logger.Int64("userId", user.Id), logger.Error(err))
 	}
 
 	return user, nil
@@ -96,12 +208,28 @@ func (r *userRepository) FindByPhone(ctx context.Context, phone string) (domain.
 	return r.entityToDomain(u), nil
 }
 
+func (r *userRepository) UpdateLocale(ctx context.Context, id int64, locale string) error {
+	if err := r.dao.UpdateLocale(ctx, id, locale); err != nil {
+		return err
+	}
+	if !r.health.Healthy() {
+		return nil
+	}
+	// Drop the cached user instead of patching it, the next read loads the new locale
+	if err := r.cache.Del(ctx, id); err != nil {
+		r.l.WithContext(ctx).Warn("delete user from cache failed",
+			logger.Int64("userId", id), logger.Error(err))
+	}
+	return nil
+}
+
 func (r *userRepository) entityToDomain(u dao.User) domain.User {
 	return domain.User{
 		Id:       u.Id,
 		Phone:    u.Phone.String,
 		Email:    u.Email.String,
 		Password: u.Password,
+		Locale:   u.Locale,
 	}
 }
 
@@ -111,5 +239,6 @@ func (r *userRepository) domainToEntity(u domain.User) dao.User {
 		Phone:    sql.NullString{String: u.Phone, Valid: u.Phone != ""},
 		Email:    sql.NullString{String: u.Email, Valid: u.Email != ""},
 		Password: u.Password,
+		Locale:   u.Locale,
 	}
 }
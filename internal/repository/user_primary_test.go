@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+
+	"github.com/cyvqet/connectify/internal/repository/cache"
+	"github.com/cyvqet/connectify/internal/repository/dao"
+	"github.com/cyvqet/connectify/pkg/logger"
+)
+
+// newLaggingReplicaRepository has a replica that never catches up, it only
+// holds the rows written to it directly
+func newLaggingReplicaRepository(t *testing.T) (UserRepository, *gorm.DB, *gorm.DB, *fakeHealth) {
+	dir := t.TempDir()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "primary.db")))
+	require.NoError(t, err)
+	replica := sqlite.Open(filepath.Join(dir, "replica.db"))
+	require.NoError(t, db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: []gorm.Dialector{replica},
+	})))
+	replicaDB, err := gorm.Open(replica)
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&dao.User{}))
+	require.NoError(t, replicaDB.AutoMigrate(&dao.User{}))
+
+	mr := miniredis.RunT(t)
+	health := &fakeHealth{}
+	repo := NewUserRepository(dao.NewUserDao(db),
+		cache.NewUserCache(redis.NewClient(&redis.Options{Addr: mr.Addr()}), cache.UserCacheConfig{Expire: time.Minute}),
+		health, 10, logger.NewNopLogger())
+	return repo, db, replicaDB, health
+}
+
+func TestUserRepository_UpdateLocale_LaggingReplica(t *testing.T) {
+	repo, db, replicaDB, health := newLaggingReplicaRepository(t)
+	ctx := context.Background()
+	user := dao.User{Id: 1, Locale: "en"}
+	require.NoError(t, db.Create(&user).Error)
+	require.NoError(t, replicaDB.Create(&user).Error)
+
+	u, err := repo.FindById(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, "en", u.Locale)
+
+	require.NoError(t, repo.UpdateLocale(ctx, 1, "zh-CN"))
+	// The refill reads the primary, the replica still has "en"
+	u, err = repo.FindById(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "zh-CN", u.Locale)
+	// And that is what stays cached
+	u, err = repo.FindById(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "zh-CN", u.Locale)
+
+	// Without the cache nothing is written back, the replica is fine
+	health.down.Store(true)
+	u, err = repo.FindById(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "en", u.Locale)
+}
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cyvqet/connectify/internal/domain"
+	"github.com/cyvqet/connectify/internal/repository/cache"
+	"github.com/cyvqet/connectify/internal/repository/dao"
+	"github.com/cyvqet/connectify/pkg/logger"
+)
+
+// slowUserDao counts FindById calls and holds each one for delay
+type slowUserDao struct {
+	dao.UserDao
+	users map[int64]dao.User
+	delay time.Duration
+	calls atomic.Int32
+}
+
+func (d *slowUserDao) FindById(ctx context.Context, id int64) (dao.User, error) {
+	d.calls.Add(1)
+	time.Sleep(d.delay)
+	u, ok := d.users[id]
+	if !ok {
+		return dao.User{}, dao.ErrUserNotFound
+	}
+	return u, nil
+}
+
+func (d *slowUserDao) Insert(ctx context.Context, u dao.User) (int64, error) {
+	u.Id = int64(len(d.users) + 1)
+	d.users[u.Id] = u
+	return u.Id, nil
+}
+
+type fakeHealth struct {
+	down atomic.Bool
+}
+
+func (h *fakeHealth) Healthy() bool { return !h.down.Load() }
+
+func newTestUserRepository(t *testing.T, d dao.UserDao, cfg cache.UserCacheConfig) (UserRepository, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	return NewUserRepository(d, cache.NewUserCache(client, cfg), &fakeHealth{}, 10, logger.NewNopLogger()), mr
+}
+
+func TestUserRepository_FindById_Coalesce(t *testing.T) {
+	d := &slowUserDao{users: map[int64]dao.User{1: {Id: 1}}, delay: 50 * time.Millisecond}
+	repo, _ := newTestUserRepository(t, d, cache.UserCacheConfig{Expire: time.Minute})
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u, err := repo.FindById(context.Background(), 1)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(1), u.Id)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), d.calls.Load())
+
+	// Served from cache now
+	_, err := repo.FindById(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), d.calls.Load())
+}
+
+func TestUserRepository_FindById_NotFound(t *testing.T) {
+	testCases := []struct {
+		name           string
+		notFoundExpire time.Duration
+		wantCalls      int32
+	}{
+		{name: "cached", notFoundExpire: time.Minute, wantCalls: 1},
+		{name: "disabled", wantCalls: 3},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &slowUserDao{users: map[int64]dao.User{}}
+			repo, mr := newTestUserRepository(t, d, cache.UserCacheConfig{
+				Expire:         time.Minute,
+				NotFoundExpire: tc.notFoundExpire,
+			})
+			for range 3 {
+				_, err := repo.FindById(context.Background(), 404)
+				assert.Equal(t, ErrUserNotFound, err)
+			}
+			assert.Equal(t, tc.wantCalls, d.calls.Load())
+			if tc.notFoundExpire > 0 {
+				assert.Equal(t, tc.notFoundExpire, mr.TTL("user:info:404"))
+			}
+		})
+	}
+}
+
+func TestUserRepository_FindById_Jitter(t *testing.T) {
+	users := map[int64]dao.User{}
+	for id := int64(1); id <= 50; id++ {
+		users[id] = dao.User{Id: id}
+	}
+	repo, mr := newTestUserRepository(t, &slowUserDao{users: users}, cache.UserCacheConfig{
+		Expire: 10 * time.Minute,
+		Jitter: 2 * time.Minute,
+	})
+
+	ttls := map[time.Duration]bool{}
+	for id := range users {
+		_, err := repo.FindById(context.Background(), id)
+		require.NoError(t, err)
+		ttl := mr.TTL(fmt.Sprintf("user:info:%d", id))
+		assert.GreaterOrEqual(t, ttl, 10*time.Minute)
+		assert.Less(t, ttl, 12*time.Minute)
+		ttls[ttl] = true
+	}
+	assert.Greater(t, len(ttls), 1)
+}
+
+func TestUserRepository_FindById_Degraded(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	d := &slowUserDao{users: map[int64]dao.User{1: {Id: 1}, 2: {Id: 2}}, delay: 50 * time.Millisecond}
+	health := &fakeHealth{}
+	health.down.Store(true)
+	repo := NewUserRepository(d, cache.NewUserCache(client, cache.UserCacheConfig{Expire: time.Minute}), health, 1, logger.NewNopLogger())
+
+	// Goes to the database without writing to the cache
+	u, err := repo.FindById(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), u.Id)
+	assert.False(t, mr.Exists("user:info:1"))
+
+	// Over the concurrency limit fails fast
+	errs := make(chan error, 2)
+	for _, id := range []int64{1, 2} {
+		go func() {
+			_, err := repo.FindById(context.Background(), id)
+			errs <- err
+		}()
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.ElementsMatch(t, []error{nil, ErrUserReadBusy}, []error{<-errs, <-errs})
+
+	// Cache errors before the checker notices are read like in degraded mode
+	health.down.Store(false)
+	mr.Close()
+	u, err = repo.FindById(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), u.Id)
+}
+
+func TestUserRepository_Create_ClearsNotFound(t *testing.T) {
+	repo, _ := newTestUserRepository(t, &slowUserDao{users: map[int64]dao.User{}}, cache.UserCacheConfig{
+		Expire:         time.Minute,
+		NotFoundExpire: time.Minute,
+	})
+	ctx := context.Background()
+
+	_, err := repo.FindById(ctx, 1)
+	require.ErrorIs(t, err, ErrUserNotFound)
+
+	require.NoError(t, repo.Create(ctx, domain.User{Phone: "13800138000"}))
+	u, err := repo.FindById(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "13800138000", u.Phone)
+}
@@ -5,64 +5,215 @@ package service
 import (
 	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"math/big"
+	"time"
 
+	"github.com/cyvqet/connectify/internal/domain"
+	"github.com/cyvqet/connectify/internal/i18n"
 	"github.com/cyvqet/connectify/internal/repository"
-	"github.com/cyvqet/connectify/internal/repository/cache"
 	"github.com/cyvqet/connectify/internal/service/sms"
+	"github.com/cyvqet/connectify/internal/service/voice"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/cyvqet/connectify/internal/service")
+
+// endSpan records err on the span unless it is nil, then ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+var (
+	ErrPhoneHourlyQuotaExceeded = repository.ErrPhoneHourlyQuotaExceeded
+	ErrPhoneDailyQuotaExceeded  = repository.ErrPhoneDailyQuotaExceeded
+	ErrIPDailyQuotaExceeded     = repository.ErrIPDailyQuotaExceeded
+	ErrBizDailyQuotaExceeded    = repository.ErrBizDailyQuotaExceeded
+	ErrUnsupportedCodeChannel   = errors.New("unsupported verification code channel")
+	// ErrCodeSendTooFrequent a code was sent within the resend interval
+	ErrCodeSendTooFrequent = repository.ErrVerificationCodeSendRateLimited
+	// ErrSMSLoginUnavailable the code store is down, users have to log in another way
+	ErrSMSLoginUnavailable = repository.ErrCodeUnavailable
+	// ErrCodeExpired no code was sent, it expired or it was already used
+	ErrCodeExpired = repository.ErrVerificationCodeExpired
+	// ErrCodeTooManyAttempts the code was invalidated, the user has to request a new one
+	ErrCodeTooManyAttempts = repository.ErrVerificationCodeCheckRateLimited
+)
+
+// CodeChannel is how the verification code reaches the user
+type CodeChannel string
+
+const (
+	CodeChannelSMS   CodeChannel = "sms"
+	CodeChannelVoice CodeChannel = "voice" // for users who never receive the SMS
 )
 
 type CodeService interface {
-	Send(ctx context.Context, bizType, phone string) (string, error)
+	// Send ip is the client ip, counted against the per-ip quota.
+	// The template is in the language set on ctx by i18n.WithLang.
+	// A voice call reads out the code already sent by SMS if it is still valid.
+	// Both channels share the resend interval and the quota.
+	Send(ctx context.Context, bizType, phone, ip string, channel CodeChannel) error
+	// Verify returns false for a wrong code that still has attempts left,
+	// ErrCodeExpired or ErrCodeTooManyAttempts when the user needs a new code
 	Verify(ctx context.Context, bizType, phone, inputCode string) (bool, error)
 }
 
+// DefaultCodePolicy applies to business types without their own policy
+var DefaultCodePolicy = domain.CodePolicy{
+	Length:         6,
+	Alphabet:       "0123456789",
+	TTL:            10 * time.Minute,
+	ResendInterval: time.Minute,
+	MaxAttempts:    3,
+}
+
+// CodePolicies maps bizType to its verification code policy
+type CodePolicies map[string]domain.CodePolicy
+
+func (p CodePolicies) Get(bizType string) domain.CodePolicy {
+	if policy, ok := p[bizType]; ok {
+		return policy
+	}
+	return DefaultCodePolicy
+}
+
 type codeService struct {
-	repo   repository.CodeRepository
-	smsSvc sms.Service
+	repo      repository.CodeRepository
+	quotaRepo repository.SMSQuotaRepository
+	smsSvc    sms.Service
+	voiceSvc  voice.Service
+	policies  CodePolicies
 }
 
-func NewCodeService(repo repository.CodeRepository, smsSvc sms.Service) CodeService {
+func NewCodeService(repo repository.CodeRepository, quotaRepo repository.SMSQuotaRepository,
+	smsSvc sms.Service, voiceSvc voice.Service, policies CodePolicies) CodeService {
 	return &codeService{
-		repo:   repo,
-		smsSvc: smsSvc,
+		repo:      repo,
+		quotaRepo: quotaRepo,
+		smsSvc:    smsSvc,
+		voiceSvc:  voiceSvc,
+		policies:  policies,
+	}
+}
+
+// codeTemplates are the template ids per channel and language,
+// the unsuffixed ones are the original Chinese templates
+var codeTemplates = map[CodeChannel]map[i18n.Lang]string{
+	CodeChannelSMS: {
+		i18n.ZhCN: "SMS_VERIFICATION_CODE",
+		i18n.En:   "SMS_VERIFICATION_CODE_EN",
+	},
+	CodeChannelVoice: {
+		i18n.ZhCN: "VOICE_VERIFICATION_CODE",
+		i18n.En:   "VOICE_VERIFICATION_CODE_EN",
+	},
+}
+
+// template picks the variant in the language carried by ctx
+func (svc *codeService) template(ctx context.Context, channel CodeChannel) string {
+	templates := codeTemplates[channel]
+	if tplId, ok := templates[i18n.FromContext(ctx)]; ok {
+		return tplId
 	}
+	return templates[i18n.Default]
 }
 
-const smsTemplateID = "SMS_VERIFICATION_CODE"
+func (svc *codeService) Send(ctx context.Context, bizType, phone, ip string, channel CodeChannel) (err error) {
+	ctx, span := tracer.Start(ctx, "CodeService.Send", trace.WithAttributes(
+		attribute.String("code.biz", bizType),
+		attribute.String("code.channel", string(channel)),
+	))
+	defer func() { endSpan(span, err) }()
 
-func (svc *codeService) Send(ctx context.Context, bizType, phone string) (string, error) {
-	verificationCode, err := svc.generate()
+	policy := svc.policies.Get(bizType)
+	var verificationCode string
+	switch channel {
+	case CodeChannelSMS:
+		verificationCode, err = svc.generate(policy)
+	case CodeChannelVoice:
+		verificationCode, err = svc.currentCode(ctx, bizType, phone, policy)
+	default:
+		return ErrUnsupportedCodeChannel
+	}
 	if err != nil {
-		return "", fmt.Errorf("generate verification code failed: %w", err)
+		return err
 	}
-	if err := svc.repo.Set(ctx, bizType, phone, verificationCode); err != nil {
-		return "", fmt.Errorf("set verification code failed: %w", err)
+
+	// Consumed before the code is stored, so a send rejected by the quota
+	// leaves the previous code valid. Both channels share the same quota.
+	if err := svc.quotaRepo.Incr(ctx, bizType, phone, ip); err != nil {
+		return fmt.Errorf("send quota check failed: %w", err)
+	}
+	// Subject to the resend window, a voice call storing the current code
+	// again restarts it like an SMS does
+	if err := svc.repo.Set(ctx, bizType, phone, verificationCode, policy); err != nil {
+		// Nothing is sent, e.g. a resend inside the window. Failing to give
+		// the quota back only makes it stricter, the error that matters is err.
+		_ = svc.quotaRepo.Decr(ctx, bizType, phone, ip)
+		return fmt.Errorf("set verification code failed: %w", err)
 	}
 
-	if err := svc.smsSvc.Send(ctx, smsTemplateID, []string{verificationCode}, phone); err != nil {
-		return "", fmt.Errorf("send sms failed: %w", err)
+	if channel == CodeChannelVoice {
+		if err := svc.voiceSvc.Send(ctx, svc.template(ctx, channel), []string{verificationCode}, phone); err != nil {
+			return fmt.Errorf("send voice call failed: %w", err)
+		}
+		return nil
 	}
 
-	return verificationCode, nil
+	if err := svc.smsSvc.Send(ctx, svc.template(ctx, channel), []string{verificationCode}, phone); err != nil {
+		return fmt.Errorf("send sms failed: %w", err)
+	}
+	return nil
 }
 
-func (svc *codeService) Verify(ctx context.Context, bizType, phone, inputCode string) (bool, error) {
-	ok, err := svc.repo.Verify(ctx, bizType, phone, inputCode)
-
-	// If the verification frequency limit is triggered, only tell the upper layer "verification failed"
-	if err == cache.ErrVerificationCodeCheckRateLimited {
-		return false, nil
+// currentCode reuses the code sent by a previous attempt so the SMS and
+// the voice call carry the same code, or generates a new one if there is none
+func (svc *codeService) currentCode(ctx context.Context, bizType, phone string,
+	policy domain.CodePolicy) (string, error) {
+	verificationCode, err := svc.repo.Get(ctx, bizType, phone)
+	switch {
+	case err == nil:
+		return verificationCode, nil
+	case errors.Is(err, repository.ErrCodeNotExist):
+		return svc.generate(policy)
+	default:
+		return "", fmt.Errorf("get verification code failed: %w", err)
 	}
-	return ok, err
 }
 
-// generate generate 6-digit random verification code (using crypto/rand to ensure unpredictability)
-func (svc *codeService) generate() (string, error) {
-	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
-	if err != nil {
-		return "", err
+func (svc *codeService) Verify(ctx context.Context, bizType, phone, inputCode string) (ok bool, err error) {
+	ctx, span := tracer.Start(ctx, "CodeService.Verify", trace.WithAttributes(
+		attribute.String("code.biz", bizType),
+	))
+	defer func() {
+		span.SetAttributes(attribute.Bool("code.ok", ok))
+		endSpan(span, err)
+	}()
+
+	return svc.repo.Verify(ctx, bizType, phone, inputCode)
+}
+
+// generate generate random verification code from the policy alphabet (using crypto/rand to ensure unpredictability)
+func (svc *codeService) generate(policy domain.CodePolicy) (string, error) {
+	alphabet := []rune(policy.Alphabet)
+	size := big.NewInt(int64(len(alphabet)))
+	code := make([]rune, policy.Length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", fmt.Errorf("generate verification code failed: %w", err)
+		}
+		code[i] = alphabet[n.Int64()]
 	}
-	return fmt.Sprintf("%06d", n.Int64()), nil
+	return string(code), nil
 }
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cyvqet/connectify/internal/domain"
+	"github.com/cyvqet/connectify/internal/repository"
+	"github.com/cyvqet/connectify/internal/repository/cache"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSender stands for the SMS and voice providers, it keeps the codes sent
+type recordingSender struct {
+	codes []string
+}
+
+func (s *recordingSender) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	s.codes = append(s.codes, args[0])
+	return nil
+}
+
+// redisHealth is what the health checker says about the miniredis
+type redisHealth struct {
+	down bool
+}
+
+func (h *redisHealth) Healthy() bool {
+	return !h.down
+}
+
+// newTestCodeService stores codes and quotas in a miniredis, whose clock
+// moves with its FastForward
+func newTestCodeService(t *testing.T, quota cache.SMSQuota) (*codeService, *recordingSender, *miniredis.Miniredis) {
+	svc, sender, mr, _ := newTestCodeServiceHealth(t, quota)
+	return svc, sender, mr
+}
+
+func newTestCodeServiceHealth(t *testing.T, quota cache.SMSQuota) (*codeService, *recordingSender, *miniredis.Miniredis, *redisHealth) {
+	mr := miniredis.RunT(t)
+	cmd := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	sender := &recordingSender{}
+	health := &redisHealth{}
+	svc := NewCodeService(
+		repository.NewCodeRepository(cache.NewCodeCache(cmd), health),
+		repository.NewSMSQuotaRepository(cache.NewSMSQuotaCache(cmd, quota), health),
+		sender, sender, CodePolicies{},
+	).(*codeService)
+	return svc, sender, mr, health
+}
+
+func TestCodeService_Send_Quota(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("quota exceeded keeps the previous code", func(t *testing.T) {
+		svc, sender, mr := newTestCodeService(t, cache.SMSQuota{PhoneHourly: 1})
+		err := svc.Send(ctx, "bizLogin", "13800138000", "10.0.0.1", CodeChannelSMS)
+		require.NoError(t, err)
+		require.Len(t, sender.codes, 1)
+
+		mr.FastForward(DefaultCodePolicy.ResendInterval + time.Second)
+		err = svc.Send(ctx, "bizLogin", "13800138000", "10.0.0.1", CodeChannelSMS)
+		assert.ErrorIs(t, err, ErrPhoneHourlyQuotaExceeded)
+		assert.Len(t, sender.codes, 1)
+
+		ok, err := svc.Verify(ctx, "bizLogin", "13800138000", sender.codes[0])
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("resend inside the window gives the quota back", func(t *testing.T) {
+		svc, sender, mr := newTestCodeService(t, cache.SMSQuota{PhoneHourly: 2})
+		err := svc.Send(ctx, "bizLogin", "13800138000", "10.0.0.1", CodeChannelSMS)
+		require.NoError(t, err)
+
+		err = svc.Send(ctx, "bizLogin", "13800138000", "10.0.0.1", CodeChannelSMS)
+		assert.ErrorIs(t, err, ErrCodeSendTooFrequent)
+
+		// The rejected resend did not count, the second send of the hour goes out
+		mr.FastForward(DefaultCodePolicy.ResendInterval + time.Second)
+		err = svc.Send(ctx, "bizLogin", "13800138000", "10.0.0.1", CodeChannelSMS)
+		require.NoError(t, err)
+		assert.Len(t, sender.codes, 2)
+	})
+}
+
+func TestCodeService_Send_RedisDown(t *testing.T) {
+	testCases := []struct {
+		name    string
+		channel CodeChannel
+	}{
+		{name: "sms", channel: CodeChannelSMS},
+		{name: "voice", channel: CodeChannelVoice},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc, sender, mr, health := newTestCodeServiceHealth(t, cache.SMSQuota{PhoneHourly: 5})
+			health.down = true
+			// Any command sent anyway would fail with a connection error
+			mr.Close()
+
+			err := svc.Send(context.Background(), "bizLogin", "13800138000", "10.0.0.1", tc.channel)
+			assert.ErrorIs(t, err, ErrSMSLoginUnavailable)
+			assert.Empty(t, sender.codes)
+		})
+	}
+}
+
+func TestCodeService_Send_Voice(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("reads out the code sent by sms", func(t *testing.T) {
+		svc, sender, mr := newTestCodeService(t, cache.SMSQuota{})
+		err := svc.Send(ctx, "bizLogin", "13800138000", "10.0.0.1", CodeChannelSMS)
+		require.NoError(t, err)
+
+		mr.FastForward(DefaultCodePolicy.ResendInterval + time.Second)
+		err = svc.Send(ctx, "bizLogin", "13800138000", "10.0.0.1", CodeChannelVoice)
+		require.NoError(t, err)
+		require.Len(t, sender.codes, 2)
+		assert.Equal(t, sender.codes[0], sender.codes[1])
+	})
+
+	t.Run("respects the resend window", func(t *testing.T) {
+		svc, sender, _ := newTestCodeService(t, cache.SMSQuota{})
+		err := svc.Send(ctx, "bizLogin", "13800138000", "10.0.0.1", CodeChannelSMS)
+		require.NoError(t, err)
+
+		err = svc.Send(ctx, "bizLogin", "13800138000", "10.0.0.1", CodeChannelVoice)
+		assert.ErrorIs(t, err, ErrCodeSendTooFrequent)
+		assert.Len(t, sender.codes, 1)
+	})
+
+	t.Run("shares the quota with sms", func(t *testing.T) {
+		svc, sender, mr := newTestCodeService(t, cache.SMSQuota{PhoneHourly: 1})
+		err := svc.Send(ctx, "bizLogin", "13800138000", "10.0.0.1", CodeChannelSMS)
+		require.NoError(t, err)
+
+		mr.FastForward(DefaultCodePolicy.ResendInterval + time.Second)
+		err = svc.Send(ctx, "bizLogin", "13800138000", "10.0.0.1", CodeChannelVoice)
+		assert.ErrorIs(t, err, ErrPhoneHourlyQuotaExceeded)
+		assert.Len(t, sender.codes, 1)
+	})
+
+	t.Run("new code without a previous one", func(t *testing.T) {
+		svc, sender, _ := newTestCodeService(t, cache.SMSQuota{})
+		err := svc.Send(ctx, "bizLogin", "13800138000", "10.0.0.1", CodeChannelVoice)
+		require.NoError(t, err)
+		require.Len(t, sender.codes, 1)
+
+		ok, err := svc.Verify(ctx, "bizLogin", "13800138000", sender.codes[0])
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+}
+
+func TestCodeService_generate(t *testing.T) {
+	testCases := []struct {
+		name   string
+		policy domain.CodePolicy
+	}{
+		{name: "digits", policy: DefaultCodePolicy},
+		{name: "letters", policy: domain.CodePolicy{Length: 8, Alphabet: "ABCDEFGHJKLMNPQRSTUVWXYZ"}},
+		{name: "multibyte", policy: domain.CodePolicy{Length: 4, Alphabet: "甲乙丙丁"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := &codeService{}
+			seen := make(map[rune]bool)
+			for range 200 {
+				code, err := svc.generate(tc.policy)
+				require.NoError(t, err)
+				runes := []rune(code)
+				require.Len(t, runes, tc.policy.Length)
+				for _, r := range runes {
+					require.Contains(t, tc.policy.Alphabet, string(r))
+					seen[r] = true
+				}
+			}
+			// Every character gets used
+			assert.Len(t, seen, len([]rune(tc.policy.Alphabet)))
+		})
+	}
+}
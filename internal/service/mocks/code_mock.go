@@ -13,6 +13,7 @@ import (
 	context "context"
 	reflect "reflect"
 
+	service "github.com/cyvqet/connectify/internal/service"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -41,18 +42,17 @@ func (m *MockCodeService) EXPECT() *MockCodeServiceMockRecorder {
 }
 
 // Send mocks base method.
-func (m *MockCodeService) Send(ctx context.Context, bizType, phone string) (string, error) {
+func (m *MockCodeService) Send(ctx context.Context, bizType, phone, ip string, channel service.CodeChannel) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Send", ctx, bizType, phone)
-	ret0, _ := ret[0].(string)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "Send", ctx, bizType, phone, ip, channel)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
 // Send indicates an expected call of Send.
-func (mr *MockCodeServiceMockRecorder) Send(ctx, bizType, phone any) *gomock.Call {
+func (mr *MockCodeServiceMockRecorder) Send(ctx, bizType, phone, ip, channel any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockCodeService)(nil).Send), ctx, bizType, phone)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockCodeService)(nil).Send), ctx, bizType, phone, ip, channel)
 }
 
 // Verify mocks base method.
@@ -0,0 +1,72 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: sms_log.go
+//
+// Generated by this command:
+//
+//	mockgen -source=sms_log.go -destination=mocks/sms_log_mock.go -package=svcmocks
+//
+
+// Package svcmocks is a generated GoMock package.
+package svcmocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	domain "github.com/cyvqet/connectify/internal/domain"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockSMSLogService is a mock of SMSLogService interface.
+type MockSMSLogService struct {
+	ctrl     *gomock.Controller
+	recorder *MockSMSLogServiceMockRecorder
+	isgomock struct{}
+}
+
+// MockSMSLogServiceMockRecorder is the mock recorder for MockSMSLogService.
+type MockSMSLogServiceMockRecorder struct {
+	mock *MockSMSLogService
+}
+
+// NewMockSMSLogService creates a new mock instance.
+func NewMockSMSLogService(ctrl *gomock.Controller) *MockSMSLogService {
+	mock := &MockSMSLogService{ctrl: ctrl}
+	mock.recorder = &MockSMSLogServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSMSLogService) EXPECT() *MockSMSLogServiceMockRecorder {
+	return m.recorder
+}
+
+// FindByPhone mocks base method.
+func (m *MockSMSLogService) FindByPhone(ctx context.Context, phone string, start, end time.Time, offset, limit int) ([]domain.SMSLog, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByPhone", ctx, phone, start, end, offset, limit)
+	ret0, _ := ret[0].([]domain.SMSLog)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByPhone indicates an expected call of FindByPhone.
+func (mr *MockSMSLogServiceMockRecorder) FindByPhone(ctx, phone, start, end, offset, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByPhone", reflect.TypeOf((*MockSMSLogService)(nil).FindByPhone), ctx, phone, start, end, offset, limit)
+}
+
+// Receipt mocks base method.
+func (m *MockSMSLogService) Receipt(ctx context.Context, provider, msgId string, delivered bool, errMsg string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Receipt", ctx, provider, msgId, delivered, errMsg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Receipt indicates an expected call of Receipt.
+func (mr *MockSMSLogServiceMockRecorder) Receipt(ctx, provider, msgId, delivered, errMsg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Receipt", reflect.TypeOf((*MockSMSLogService)(nil).Receipt), ctx, provider, msgId, delivered, errMsg)
+}
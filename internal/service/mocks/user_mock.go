@@ -14,7 +14,6 @@ import (
 	reflect "reflect"
 
 	domain "github.com/cyvqet/connectify/internal/domain"
-
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -58,11 +57,12 @@ func (mr *MockUserServiceMockRecorder) FindOrCreate(ctx, phone any) *gomock.Call
 }
 
 // Login mocks base method.
-func (m *MockUserService) Login(ctx context.Context, email, password string) error {
+func (m *MockUserService) Login(ctx context.Context, email, password string) (domain.User, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Login", ctx, email, password)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(domain.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // Login indicates an expected call of Login.
@@ -86,6 +86,20 @@ func (mr *MockUserServiceMockRecorder) Profile(ctx, id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Profile", reflect.TypeOf((*MockUserService)(nil).Profile), ctx, id)
 }
 
+// SetLocale mocks base method.
+func (m *MockUserService) SetLocale(ctx context.Context, id int64, locale string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetLocale", ctx, id, locale)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetLocale indicates an expected call of SetLocale.
+func (mr *MockUserServiceMockRecorder) SetLocale(ctx, id, locale any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLocale", reflect.TypeOf((*MockUserService)(nil).SetLocale), ctx, id, locale)
+}
+
 // SignUp mocks base method.
 func (m *MockUserService) SignUp(ctx context.Context, user domain.User) error {
 	m.ctrl.T.Helper()
@@ -2,29 +2,47 @@ package aliyun
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 
-	"go.uber.org/zap"
+	"github.com/cyvqet/connectify/internal/service/sms"
+	"github.com/cyvqet/connectify/pkg/logger"
 )
 
 type Service struct {
 	appId    string
 	signName string
+	l        logger.Logger
 }
 
-func NewService(appId, signName string) *Service {
+func NewService(appId, signName string, l logger.Logger) *Service {
 	return &Service{
 		appId:    appId,
 		signName: signName,
+		l:        l,
 	}
 }
 
 func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
-	zap.L().Info("aliyun sms send",
-		zap.String("appId", s.appId),
-		zap.String("signName", s.signName),
-		zap.String("tplId", tplId),
-		zap.Strings("args", args),
-		zap.Strings("numbers", numbers),
+	s.l.WithContext(ctx).Info("aliyun sms send",
+		logger.String("appId", s.appId),
+		logger.String("signName", s.signName),
+		logger.String("tplId", tplId),
+		logger.Strings("args", args),
+		logger.Strings("numbers", numbers),
 	)
+
+	// The provider returns one serial number per phone, used to match delivery receipts
+	msgIds := make(map[string]string, len(numbers))
+	for _, number := range numbers {
+		msgIds[number] = s.newMsgId()
+	}
+	sms.ReportSend(ctx, "aliyun", msgIds)
 	return nil
 }
+
+func (s *Service) newMsgId() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
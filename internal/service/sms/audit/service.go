@@ -0,0 +1,61 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/cyvqet/connectify/internal/domain"
+	"github.com/cyvqet/connectify/internal/repository"
+	"github.com/cyvqet/connectify/internal/service/sms"
+	"github.com/cyvqet/connectify/pkg/logger"
+)
+
+// Service records every send attempt, one row per phone number
+type Service struct {
+	smsSvc sms.Service
+	repo   repository.SMSLogRepository
+	l      logger.Logger
+}
+
+func NewService(smsSvc sms.Service, repo repository.SMSLogRepository, l logger.Logger) sms.Service {
+	return &Service{
+		smsSvc: smsSvc,
+		repo:   repo,
+		l:      l,
+	}
+}
+
+func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	sendCtx, info := sms.WithSendInfo(ctx)
+	start := time.Now()
+	err := s.smsSvc.Send(sendCtx, tplId, args, numbers...)
+	latency := time.Since(start)
+
+	status := domain.SMSStatusSent
+	var errMsg string
+	if err != nil {
+		status = domain.SMSStatusFailed
+		errMsg = err.Error()
+	}
+
+	logs := make([]domain.SMSLog, 0, len(numbers))
+	for _, number := range numbers {
+		logs = append(logs, domain.SMSLog{
+			Phone:         number,
+			TplId:         tplId,
+			Provider:      info.Provider,
+			ProviderMsgId: info.MsgIds[number],
+			Latency:       latency,
+			Status:        status,
+			ErrMsg:        errMsg,
+		})
+	}
+
+	// The audit log must never block sending, only log if it fails
+	if er := s.repo.Create(context.WithoutCancel(ctx), logs); er != nil {
+		s.l.WithContext(ctx).Error("record sms audit log failed",
+			logger.String("tplId", tplId),
+			logger.Error(er))
+	}
+	return err
+}
@@ -0,0 +1,96 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cyvqet/connectify/internal/domain"
+	"github.com/cyvqet/connectify/internal/repository"
+	"github.com/cyvqet/connectify/internal/service/sms"
+	"github.com/cyvqet/connectify/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// provider reports the message ids like the real providers, or fails with err
+type provider struct {
+	err error
+}
+
+func (p provider) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	if p.err != nil {
+		return p.err
+	}
+	msgIds := make(map[string]string, len(numbers))
+	for _, number := range numbers {
+		msgIds[number] = "msg-" + number
+	}
+	sms.ReportSend(ctx, "tencent", msgIds)
+	return nil
+}
+
+// logRepository keeps the created logs, or fails with err
+type logRepository struct {
+	repository.SMSLogRepository
+	logs []domain.SMSLog
+	err  error
+}
+
+func (r *logRepository) Create(ctx context.Context, logs []domain.SMSLog) error {
+	r.logs = append(r.logs, logs...)
+	return r.err
+}
+
+func TestService_Send(t *testing.T) {
+	testCases := []struct {
+		name     string
+		provider provider
+		repoErr  error
+
+		wantErr  error
+		wantLogs []domain.SMSLog
+	}{
+		{
+			name: "one log per number",
+			wantLogs: []domain.SMSLog{
+				{Phone: "13800000001", TplId: "tpl-1", Provider: "tencent", ProviderMsgId: "msg-13800000001", Status: domain.SMSStatusSent},
+				{Phone: "13800000002", TplId: "tpl-1", Provider: "tencent", ProviderMsgId: "msg-13800000002", Status: domain.SMSStatusSent},
+			},
+		},
+		{
+			name:     "failed send",
+			provider: provider{err: errors.New("provider down")},
+			wantErr:  errors.New("provider down"),
+			wantLogs: []domain.SMSLog{
+				{Phone: "13800000001", TplId: "tpl-1", Status: domain.SMSStatusFailed, ErrMsg: "provider down"},
+				{Phone: "13800000002", TplId: "tpl-1", Status: domain.SMSStatusFailed, ErrMsg: "provider down"},
+			},
+		},
+		{
+			// The audit log never fails the send
+			name:    "log not stored",
+			repoErr: errors.New("db down"),
+			wantLogs: []domain.SMSLog{
+				{Phone: "13800000001", TplId: "tpl-1", Provider: "tencent", ProviderMsgId: "msg-13800000001", Status: domain.SMSStatusSent},
+				{Phone: "13800000002", TplId: "tpl-1", Provider: "tencent", ProviderMsgId: "msg-13800000002", Status: domain.SMSStatusSent},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &logRepository{err: tc.repoErr}
+			svc := NewService(tc.provider, repo, logger.NewNopLogger())
+
+			err := svc.Send(context.Background(), "tpl-1", []string{"123456"}, "13800000001", "13800000002")
+			assert.Equal(t, tc.wantErr, err)
+			require.Len(t, repo.logs, len(tc.wantLogs))
+			for i := range repo.logs {
+				// Not worth asserting on
+				repo.logs[i].Latency = 0
+			}
+			assert.Equal(t, tc.wantLogs, repo.logs)
+		})
+	}
+}
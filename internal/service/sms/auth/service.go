@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/cyvqet/connectify/internal/service/sms"
+	"github.com/cyvqet/connectify/pkg/logger"
+	"github.com/cyvqet/connectify/pkg/ratelimit"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrInvalidToken       = errors.New("invalid sms service token")
+	ErrTemplateNotAllowed = errors.New("sms template not allowed for business")
+	ErrQuotaExceeded      = errors.New("sms quota exceeded for business")
+)
+
+// Claims identifies the business calling the internal SMS API
+type Claims struct {
+	Biz string
+	jwt.RegisteredClaims
+}
+
+// Policy describes what a business is allowed to send
+type Policy struct {
+	Templates []string          // whitelisted template ids
+	Limiter   ratelimit.Limiter // per-business send quota
+}
+
+type Service struct {
+	smsSvc   sms.Service
+	key      []byte
+	policies map[string]Policy
+	l        logger.Logger
+}
+
+func NewService(smsSvc sms.Service, key []byte, policies map[string]Policy, l logger.Logger) sms.Service {
+	return &Service{
+		smsSvc:   smsSvc,
+		key:      key,
+		policies: policies,
+		l:        l,
+	}
+}
+
+type tokenKey struct{}
+
+// WithToken attaches the caller's service token to ctx, Send reads it from there
+func WithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenKey{}, token)
+}
+
+// NewToken signs a service token for biz, used when onboarding a new caller
+func NewToken(key []byte, biz string, expiresAt time.Time) (string, error) {
+	claims := Claims{
+		Biz: biz,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+}
+
+func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	l := s.l.WithContext(ctx)
+	token, _ := ctx.Value(tokenKey{}).(string)
+	claims, err := s.parse(token)
+	if err != nil {
+		l.Warn("internal sms rejected, invalid token", logger.Error(err))
+		return ErrInvalidToken
+	}
+
+	policy, ok := s.policies[claims.Biz]
+	if !ok {
+		l.Warn("internal sms rejected, unknown business", logger.String("biz", claims.Biz))
+		return ErrInvalidToken
+	}
+	if !slices.Contains(policy.Templates, tplId) {
+		l.Warn("internal sms rejected, template not allowed",
+			logger.String("biz", claims.Biz), logger.String("tplId", tplId))
+		return ErrTemplateNotAllowed
+	}
+
+	limited, err := policy.Limiter.Limit(ctx, "sms-auth:"+claims.Biz)
+	if err != nil {
+		return err
+	}
+	if limited {
+		l.Warn("internal sms rejected, quota exceeded", logger.String("biz", claims.Biz))
+		return ErrQuotaExceeded
+	}
+
+	err = s.smsSvc.Send(ctx, tplId, args, numbers...)
+	if err != nil {
+		l.Error("internal sms send failed",
+			logger.String("biz", claims.Biz),
+			logger.String("tplId", tplId),
+			logger.Int("numbers", len(numbers)),
+			logger.Error(err))
+		return err
+	}
+
+	l.Info("internal sms sent",
+		logger.String("biz", claims.Biz),
+		logger.String("tplId", tplId),
+		logger.Int("numbers", len(numbers)))
+	return nil
+}
+
+func (s *Service) parse(token string) (Claims, error) {
+	var claims Claims
+	if token == "" {
+		return claims, errors.New("missing token")
+	}
+	parsed, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
+		return s.key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return claims, err
+	}
+	if !parsed.Valid || claims.Biz == "" {
+		return claims, errors.New("token does not identify a business")
+	}
+	return claims, nil
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cyvqet/connectify/pkg/logger"
+	"github.com/cyvqet/connectify/pkg/ratelimit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingSMS stands for the provider, it counts the messages that got through
+type countingSMS struct {
+	sent int
+}
+
+func (s *countingSMS) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	s.sent++
+	return nil
+}
+
+func TestService_Send(t *testing.T) {
+	key := []byte("internal-sms-test-key")
+	token := func(t *testing.T, key []byte, biz string, expiresAt time.Time) string {
+		tk, err := NewToken(key, biz, expiresAt)
+		require.NoError(t, err)
+		return tk
+	}
+	valid := func(t *testing.T) string {
+		return token(t, key, "order", time.Now().Add(time.Hour))
+	}
+
+	testCases := []struct {
+		name  string
+		token func(t *testing.T) string
+		tplId string
+		// Sends of the business before this one, each within its rate
+		before int
+
+		wantErr error
+	}{
+		{
+			name:  "allowed",
+			token: valid,
+			tplId: "tpl-order",
+		},
+		{
+			name:    "no token",
+			token:   func(t *testing.T) string { return "" },
+			tplId:   "tpl-order",
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "malformed token",
+			token:   func(t *testing.T) string { return "not-a-jwt" },
+			tplId:   "tpl-order",
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "signed with another key",
+			token: func(t *testing.T) string {
+				return token(t, []byte("another-internal-key"), "order", time.Now().Add(time.Hour))
+			},
+			tplId:   "tpl-order",
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "expired token",
+			token: func(t *testing.T) string {
+				return token(t, key, "order", time.Now().Add(-time.Minute))
+			},
+			tplId:   "tpl-order",
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "unknown business",
+			token: func(t *testing.T) string {
+				return token(t, key, "marketing", time.Now().Add(time.Hour))
+			},
+			tplId:   "tpl-order",
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "template outside the whitelist",
+			token:   valid,
+			tplId:   "tpl-login",
+			wantErr: ErrTemplateNotAllowed,
+		},
+		{
+			name:    "business over its rate",
+			token:   valid,
+			tplId:   "tpl-order",
+			before:  2,
+			wantErr: ErrQuotaExceeded,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := &countingSMS{}
+			svc := NewService(provider, key, map[string]Policy{
+				"order": {
+					Templates: []string{"tpl-order"},
+					Limiter:   ratelimit.NewLocalTokenBucketLimiter(time.Minute, 2, 2),
+				},
+			}, logger.NewNopLogger())
+
+			for i := 0; i < tc.before; i++ {
+				require.NoError(t, svc.Send(WithToken(context.Background(), valid(t)),
+					"tpl-order", nil, "13800138000"))
+			}
+			err := svc.Send(WithToken(context.Background(), tc.token(t)), tc.tplId, nil, "13800138000")
+			assert.Equal(t, tc.wantErr, err)
+			want := tc.before
+			if tc.wantErr == nil {
+				want++
+			}
+			assert.Equal(t, want, provider.sent)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/cyvqet/connectify/internal/service/sms"
+	"github.com/cyvqet/connectify/pkg/prometheusx"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Service records the latency and outcome of one provider. Wrap every
+// provider on its own so failover attempts are visible per provider.
+type Service struct {
+	smsSvc   sms.Service
+	provider string
+	duration *prometheus.HistogramVec
+}
+
+// NewService registers the histogram with reg, the services of all
+// providers share it
+func NewService(smsSvc sms.Service, provider string, reg prometheus.Registerer) sms.Service {
+	return &Service{
+		smsSvc:   smsSvc,
+		provider: provider,
+		duration: prometheusx.MustRegister(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "connectify",
+			Subsystem: "sms",
+			Name:      "send_duration_seconds",
+			Help:      "SMS provider send latency, its count is the number of send calls",
+			Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, []string{"provider", "result"})),
+	}
+}
+
+func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	start := time.Now()
+	err := s.smsSvc.Send(ctx, tplId, args, numbers...)
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	s.duration.WithLabelValues(s.provider, result).Observe(time.Since(start).Seconds())
+	return err
+}
@@ -0,0 +1,47 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/cyvqet/connectify/internal/service/sms"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Service creates a span per send of one provider. Wrap every provider on
+// its own so failover attempts show up as sibling spans.
+type Service struct {
+	smsSvc   sms.Service
+	provider string
+	tracer   trace.Tracer
+}
+
+func NewService(smsSvc sms.Service, provider string) sms.Service {
+	return &Service{
+		smsSvc:   smsSvc,
+		provider: provider,
+		tracer:   otel.Tracer("github.com/cyvqet/connectify/internal/service/sms"),
+	}
+}
+
+func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	// Numbers and args stay out of the span, they are personal data and codes
+	ctx, span := s.tracer.Start(ctx, "sms:send",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("sms.provider", s.provider),
+			attribute.String("sms.tpl_id", tplId),
+			attribute.Int("sms.numbers", len(numbers)),
+		))
+	defer span.End()
+
+	err := s.smsSvc.Send(ctx, tplId, args, numbers...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
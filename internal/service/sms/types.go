@@ -5,3 +5,28 @@ import "context"
 type Service interface {
 	Send(ctx context.Context, tplId string, args []string, numbers ...string) error
 }
+
+// SendInfo is filled in by the provider that actually sent the message,
+// so decorators can tell which provider was chosen behind a failover
+type SendInfo struct {
+	Provider string
+	MsgIds   map[string]string // phone number → provider message id
+}
+
+type sendInfoKey struct{}
+
+// WithSendInfo returns a ctx that collects SendInfo from the provider
+func WithSendInfo(ctx context.Context) (context.Context, *SendInfo) {
+	info := &SendInfo{}
+	return context.WithValue(ctx, sendInfoKey{}, info), info
+}
+
+// ReportSend is called by providers after a successful send
+func ReportSend(ctx context.Context, provider string, msgIds map[string]string) {
+	info, ok := ctx.Value(sendInfoKey{}).(*SendInfo)
+	if !ok {
+		return
+	}
+	info.Provider = provider
+	info.MsgIds = msgIds
+}
@@ -0,0 +1,39 @@
+package service
+
+//go:generate mockgen -source=sms_log.go -destination=mocks/sms_log_mock.go -package=svcmocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/cyvqet/connectify/internal/domain"
+	"github.com/cyvqet/connectify/internal/repository"
+)
+
+type SMSLogService interface {
+	// Receipt applies a provider delivery receipt to the matching audit record
+	Receipt(ctx context.Context, provider, msgId string, delivered bool, errMsg string) error
+	FindByPhone(ctx context.Context, phone string, start, end time.Time, offset, limit int) ([]domain.SMSLog, error)
+}
+
+type smsLogService struct {
+	repo repository.SMSLogRepository
+}
+
+func NewSMSLogService(repo repository.SMSLogRepository) SMSLogService {
+	return &smsLogService{
+		repo: repo,
+	}
+}
+
+func (svc *smsLogService) Receipt(ctx context.Context, provider, msgId string, delivered bool, errMsg string) error {
+	status := domain.SMSStatusUndelivered
+	if delivered {
+		status = domain.SMSStatusDelivered
+	}
+	return svc.repo.UpdateStatus(ctx, provider, msgId, status, errMsg)
+}
+
+func (svc *smsLogService) FindByPhone(ctx context.Context, phone string, start, end time.Time, offset, limit int) ([]domain.SMSLog, error) {
+	return svc.repo.FindByPhone(ctx, phone, start, end, offset, limit)
+}
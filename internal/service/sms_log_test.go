@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cyvqet/connectify/internal/domain"
+	"github.com/cyvqet/connectify/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// statusRepository keeps the last status update
+type statusRepository struct {
+	repository.SMSLogRepository
+	provider, msgId string
+	status          domain.SMSStatus
+	errMsg          string
+}
+
+func (r *statusRepository) UpdateStatus(ctx context.Context, provider, msgId string, status domain.SMSStatus, errMsg string) error {
+	r.provider, r.msgId, r.status, r.errMsg = provider, msgId, status, errMsg
+	return nil
+}
+
+func TestSMSLogService_Receipt(t *testing.T) {
+	testCases := []struct {
+		name      string
+		delivered bool
+		errMsg    string
+
+		wantStatus domain.SMSStatus
+	}{
+		{name: "delivered", delivered: true, wantStatus: domain.SMSStatusDelivered},
+		{name: "undelivered", errMsg: "handset off", wantStatus: domain.SMSStatusUndelivered},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &statusRepository{}
+			svc := NewSMSLogService(repo)
+
+			err := svc.Receipt(context.Background(), "tencent", "msg-1", tc.delivered, tc.errMsg)
+			require.NoError(t, err)
+			assert.Equal(t, "tencent", repo.provider)
+			assert.Equal(t, "msg-1", repo.msgId)
+			assert.Equal(t, tc.wantStatus, repo.status)
+			assert.Equal(t, tc.errMsg, repo.errMsg)
+		})
+	}
+}
@@ -16,14 +16,17 @@ import (
 var (
 	ErrUserDuplicateEmail    = repository.ErrUserDuplicateEmail
 	ErrUserNotFound          = repository.ErrUserNotFound
+	ErrUserReadBusy          = repository.ErrUserReadBusy
 	ErrInvaildUserOrPassword = errors.New("invalid username or password")
 )
 
 type UserService interface {
 	SignUp(ctx context.Context, user domain.User) error
-	Login(ctx context.Context, email string, password string) error
+	Login(ctx context.Context, email string, password string) (domain.User, error)
 	Profile(ctx context.Context, id int64) (domain.User, error)
 	FindOrCreate(ctx context.Context, phone string) (domain.User, error)
+	// SetLocale stores the language the user wants messages and SMS in
+	SetLocale(ctx context.Context, id int64, locale string) error
 }
 
 type userService struct {
@@ -47,22 +50,22 @@ func (svc *userService) SignUp(ctx context.Context, user domain.User) error {
 	return svc.repo.Create(ctx, user)
 }
 
-func (svc *userService) Login(ctx context.Context, email string, password string) error {
+func (svc *userService) Login(ctx context.Context, email string, password string) (domain.User, error) {
 	user, err := svc.repo.FindByEmail(ctx, email)
 	if err == ErrUserNotFound {
-		return ErrInvaildUserOrPassword
+		return domain.User{}, ErrInvaildUserOrPassword
 	}
 	if err != nil {
-		return err
+		return domain.User{}, err
 	}
 
 	// Compare password
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 	if err != nil {
 		// Log error
-		return ErrInvaildUserOrPassword
+		return domain.User{}, ErrInvaildUserOrPassword
 	}
-	return nil
+	return user, nil
 }
 
 func (svc *userService) Profile(ctx context.Context, id int64) (domain.User, error) {
@@ -70,6 +73,9 @@ func (svc *userService) Profile(ctx context.Context, id int64) (domain.User, err
 }
 
 func (svc *userService) FindOrCreate(ctx context.Context, phone string) (domain.User, error) {
+	// A replica may not have the user created just now, here or by a concurrent login
+	ctx = repository.WithPrimary(ctx)
+
 	// Check if user exists by phone number
 	user, err := svc.repo.FindByPhone(ctx, phone)
 	if err == nil {
@@ -100,3 +106,7 @@ func (svc *userService) FindOrCreate(ctx context.Context, phone string) (domain.
 	return createdUser, nil
 
 }
+
+func (svc *userService) SetLocale(ctx context.Context, id int64, locale string) error {
+	return svc.repo.UpdateLocale(ctx, id, locale)
+}
@@ -0,0 +1,57 @@
+package local
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cyvqet/connectify/pkg/logger"
+)
+
+// Call is one voice call placed by the fake provider
+type Call struct {
+	TplId  string
+	Args   []string
+	Number string
+}
+
+// maxCalls bounds the recorded history, a long running dev server keeps calling
+const maxCalls = 100
+
+// Service is a fake provider that records calls instead of dialing,
+// used for local development and tests. Only the latest maxCalls are kept.
+type Service struct {
+	mu    sync.Mutex
+	calls []Call
+	l     logger.Logger
+}
+
+func NewService(l logger.Logger) *Service {
+	return &Service{l: l}
+}
+
+func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	s.l.WithContext(ctx).Info("local voice call",
+		logger.String("tplId", tplId),
+		logger.Strings("args", args),
+		logger.Strings("numbers", numbers),
+	)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, number := range numbers {
+		s.calls = append(s.calls, Call{TplId: tplId, Args: args, Number: number})
+	}
+	if n := len(s.calls) - maxCalls; n > 0 {
+		s.calls = append(s.calls[:0], s.calls[n:]...)
+	}
+	return nil
+}
+
+// Calls returns the latest calls placed, oldest first
+func (s *Service) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]Call, len(s.calls))
+	copy(res, s.calls)
+	return res
+}
@@ -0,0 +1,26 @@
+package local
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/cyvqet/connectify/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_Calls(t *testing.T) {
+	svc := NewService(logger.NewNopLogger())
+	ctx := context.Background()
+	for i := range maxCalls + 10 {
+		require.NoError(t, svc.Send(ctx, "tpl-1", []string{strconv.Itoa(i)}, "13800138000"))
+	}
+
+	calls := svc.Calls()
+	require.Len(t, calls, maxCalls)
+	// The oldest ones were dropped
+	assert.Equal(t, []string{"10"}, calls[0].Args)
+	assert.Equal(t, []string{strconv.Itoa(maxCalls + 9)}, calls[len(calls)-1].Args)
+}
@@ -0,0 +1,8 @@
+package voice
+
+import "context"
+
+// Service reads the args of template tplId to the callee by phone call
+type Service interface {
+	Send(ctx context.Context, tplId string, args []string, numbers ...string) error
+}
@@ -0,0 +1,47 @@
+package web
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var errInvalidToken = errors.New("invalid token")
+
+// JWTHandler signs and verifies the login tokens with key, see jwt.key
+type JWTHandler struct {
+	key []byte
+}
+
+func NewJWTHandler(key []byte) *JWTHandler {
+	return &JWTHandler{
+		key: key,
+	}
+}
+
+// SetToken signs claims and hands the token to the client in the Jwt-Token header
+func (h *JWTHandler) SetToken(c *gin.Context, claims UserClaims) error {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenStr, err := token.SignedString(h.key)
+	if err != nil {
+		return err
+	}
+	c.Header("Jwt-Token", tokenStr)
+	return nil
+}
+
+// ParseToken verifies token and returns its claims, expired tokens fail
+func (h *JWTHandler) ParseToken(token string) (UserClaims, error) {
+	var claims UserClaims
+	jwtToken, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
+		return h.key, nil
+	})
+	if err != nil {
+		return UserClaims{}, err
+	}
+	if !jwtToken.Valid {
+		return UserClaims{}, errInvalidToken
+	}
+	return claims, nil
+}
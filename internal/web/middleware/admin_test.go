@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cyvqet/connectify/internal/web"
+	"github.com/cyvqet/connectify/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminMiddlewareBuilder(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		claims   *web.UserClaims
+		wantCode int
+	}{
+		{
+			name:     "admin",
+			path:     "/admin/log/level",
+			claims:   &web.UserClaims{UserId: 1},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "other user",
+			path:     "/admin/log/level",
+			claims:   &web.UserClaims{UserId: 2},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "no user",
+			path:     "/admin/sms/logs",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "not an admin route",
+			path:     "/user/profile",
+			claims:   &web.UserClaims{UserId: 2},
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				// Set by the JWT middleware
+				if tc.claims != nil {
+					ctx.Set("claim", *tc.claims)
+				}
+			})
+			server.Use(NewAdminMiddlewareBuilder("/admin/", logger.NewNopLogger()).Admins(1).Build())
+			server.GET(tc.path, func(ctx *gin.Context) {
+				ctx.Status(http.StatusOK)
+			})
+
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			assert.Equal(t, tc.wantCode, recorder.Code)
+		})
+	}
+}
@@ -1,21 +1,25 @@
 package middleware
 
 import (
-	"log"
-	"net/http"
 	"slices"
 	"time"
 
+	"github.com/cyvqet/connectify/internal/errs"
+	"github.com/cyvqet/connectify/internal/web"
+	"github.com/cyvqet/connectify/pkg/ginx"
+	"github.com/cyvqet/connectify/pkg/logger"
+
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 )
 
 type LoginMiddlewareBuilder struct {
 	paths []string
+	l     logger.Logger
 }
 
-func NewLoginMiddlewareBuilder() *LoginMiddlewareBuilder {
-	return &LoginMiddlewareBuilder{}
+func NewLoginMiddlewareBuilder(l logger.Logger) *LoginMiddlewareBuilder {
+	return &LoginMiddlewareBuilder{l: l}
 }
 
 func (l *LoginMiddlewareBuilder) IgnorePath(paths string) *LoginMiddlewareBuilder {
@@ -33,11 +37,11 @@ func (l *LoginMiddlewareBuilder) Build() gin.HandlerFunc {
 		session := sessions.Default(ctx)
 		email := session.Get("userEmail")
 		if email == nil {
-			ctx.JSON(http.StatusUnauthorized, gin.H{"message": "not logged in"})
-			ctx.Abort()
+			ginx.Abort(ctx, web.Result(ctx, errs.Unauthorized))
 			return
 		}
 
+		log := l.l.WithContext(ctx.Request.Context())
 		session.Options(sessions.Options{
 			MaxAge: 3600,
 		})
@@ -46,7 +50,7 @@ func (l *LoginMiddlewareBuilder) Build() gin.HandlerFunc {
 		updateTime := session.Get("update_time")
 		now := time.Now().UnixMilli()
 		if updateTime == nil {
-			log.Println("First refresh session time")
+			log.Debug("first refresh session time")
 			session.Set("update_time", now)
 			session.Save()
 			ctx.Next()
@@ -55,19 +59,17 @@ func (l *LoginMiddlewareBuilder) Build() gin.HandlerFunc {
 
 		updateTimeValue, ok := updateTime.(int64)
 		if !ok {
-			log.Println("Session time format error")
-			ctx.JSON(http.StatusInternalServerError, gin.H{"message": "system error"})
-			ctx.Abort()
+			log.Error("session time format error", logger.Any("updateTime", updateTime))
+			ginx.Abort(ctx, web.Result(ctx, errs.Internal))
 			return
 		}
 
 		if now-updateTimeValue > 60*1000 { // 1 minute no operation, refresh session
-			log.Println("Refresh session time")
+			log.Debug("refresh session time")
 			session.Set("update_time", now)
 			session.Save()
 		}
 
-		log.Printf("Current session user email: %v\n", email)
 		ctx.Next()
 	}
 }
@@ -1,13 +1,14 @@
 package middleware
 
 import (
-	"fmt"
-	"net/http"
 	"slices"
 	"strings"
 	"time"
 
+	"github.com/cyvqet/connectify/internal/errs"
 	"github.com/cyvqet/connectify/internal/web"
+	"github.com/cyvqet/connectify/pkg/ginx"
+	"github.com/cyvqet/connectify/pkg/logger"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -20,11 +21,13 @@ const (
 )
 
 type LoginJwtMiddlewareBuilder struct {
-	paths []string
+	paths  []string
+	jwtHdl *web.JWTHandler
+	l      logger.Logger
 }
 
-func NewLoginJwtMiddlewareBuilder() *LoginJwtMiddlewareBuilder {
-	return &LoginJwtMiddlewareBuilder{}
+func NewLoginJwtMiddlewareBuilder(jwtHdl *web.JWTHandler, l logger.Logger) *LoginJwtMiddlewareBuilder {
+	return &LoginJwtMiddlewareBuilder{jwtHdl: jwtHdl, l: l}
 }
 
 func (l *LoginJwtMiddlewareBuilder) IgnorePath(paths string) *LoginJwtMiddlewareBuilder {
@@ -45,48 +48,43 @@ func (l *LoginJwtMiddlewareBuilder) Build() gin.HandlerFunc {
 		// If validation succeeds, call ctx.Next() to continue processing the request
 		tokenHeader := ctx.GetHeader("Authorization")
 		if tokenHeader == "" {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "未授权"})
+			ginx.Abort(ctx, web.Result(ctx, errs.Unauthorized))
 			return
 		}
 
 		segs := strings.Split(tokenHeader, " ")
 		if len(segs) != 2 || segs[0] != "Bearer" {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "未授权"})
+			ginx.Abort(ctx, web.Result(ctx, errs.Unauthorized))
 			return
 		}
-		claim := web.UserClaims{} // Custom Claims structure
-		token := segs[1]
-		jwtToken, err := jwt.ParseWithClaims(token, &claim, func(t *jwt.Token) (any, error) {
-			return []byte("secret"), nil
-		})
-
-		if err != nil || !jwtToken.Valid { // Token expired, return false
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "未授权"})
+		claim, err := l.jwtHdl.ParseToken(segs[1])
+		if err != nil { // Token expired or forged
+			ginx.Abort(ctx, web.Result(ctx, errs.Unauthorized))
 			return
 		}
 
 		if claim.UserAgent != ctx.Request.UserAgent() { // Check if UserAgent is consistent
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "未授权"})
+			ginx.Abort(ctx, web.Result(ctx, errs.Unauthorized))
 			return
 		}
 
+		// Later log entries of this request carry the user id
+		ctx.Request = ctx.Request.WithContext(
+			logger.WithFields(ctx.Request.Context(), logger.Int64("userId", claim.UserId)))
+		log := l.l.WithContext(ctx.Request.Context())
+
 		remaining := time.Until(claim.ExpiresAt.Time)
-		fmt.Printf("token remaining time: %v\n", remaining)
 		if remaining <= refreshWhen {
-			fmt.Printf("token will expire in %v, refresh token\n", remaining)
+			log.Debug("refresh token", logger.Duration("remaining", remaining))
 			// Refresh token expiration time
 			claim.ExpiresAt = jwt.NewNumericDate(time.Now().Add(newTokenTTL))
 
-			// Generate new JWT token
-			newToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claim)
-			newTokenStr, err := newToken.SignedString([]byte("secret"))
-			if err != nil {
-				ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "system error"})
+			// Return new token to client
+			if err := l.jwtHdl.SetToken(ctx, claim); err != nil {
+				log.Error("sign refreshed token failed", logger.Error(err))
+				ginx.Abort(ctx, web.Result(ctx, errs.Internal))
 				return
 			}
-
-			// Return new token to client
-			ctx.Header("Jwt-Token", newTokenStr)
 		}
 
 		// Store claim in context for subsequent processing
@@ -0,0 +1,79 @@
+package web
+
+import (
+	"errors"
+
+	"github.com/cyvqet/connectify/internal/errs"
+	"github.com/cyvqet/connectify/internal/i18n"
+	"github.com/cyvqet/connectify/internal/service"
+	"github.com/cyvqet/connectify/internal/service/sms/auth"
+	"github.com/cyvqet/connectify/pkg/ginx"
+	"github.com/cyvqet/connectify/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serviceErrs maps the service errors a client can act on to their codes
+var serviceErrs = []struct {
+	err  error
+	code int
+}{
+	{service.ErrInvaildUserOrPassword, errs.InvalidUserOrPassword},
+	{service.ErrUserDuplicateEmail, errs.EmailConflict},
+	{service.ErrUserNotFound, errs.UserNotFound},
+	{service.ErrUserReadBusy, errs.SystemBusy},
+
+	{service.ErrUnsupportedCodeChannel, errs.UnsupportedCodeChannel},
+	{service.ErrCodeSendTooFrequent, errs.CodeSendTooFrequent},
+	{service.ErrCodeExpired, errs.CodeExpired},
+	{service.ErrCodeTooManyAttempts, errs.CodeTooManyAttempts},
+	{service.ErrPhoneHourlyQuotaExceeded, errs.PhoneHourlyQuotaExceeded},
+	{service.ErrPhoneDailyQuotaExceeded, errs.PhoneDailyQuotaExceeded},
+	{service.ErrIPDailyQuotaExceeded, errs.IPDailyQuotaExceeded},
+	{service.ErrBizDailyQuotaExceeded, errs.BizDailyQuotaExceeded},
+	{service.ErrSMSLoginUnavailable, errs.SMSLoginUnavailable},
+
+	{auth.ErrInvalidToken, errs.InvalidServiceToken},
+	{auth.ErrTemplateNotAllowed, errs.SMSTemplateNotAllowed},
+	{auth.ErrQuotaExceeded, errs.SMSQuotaExceeded},
+}
+
+// Lang is the language to answer c in: the user's preference once logged in,
+// otherwise the best match of Accept-Language
+func Lang(c *gin.Context) i18n.Lang {
+	if claims, ok := userClaims(c); ok {
+		if lang, ok := i18n.Parse(claims.Locale); ok {
+			return lang
+		}
+	}
+	return i18n.Match(c.GetHeader("Accept-Language"))
+}
+
+// NewWrapper is a ginx.Wrapper answering bodies that fail to bind in the
+// language of the request
+func NewWrapper(l logger.Logger) *ginx.Wrapper {
+	return ginx.NewWrapper(l).BindErrorResult(func(c *gin.Context) ginx.Result {
+		return Result(c, errs.InvalidInput)
+	})
+}
+
+// Result builds the result of code with its message in the language of c
+func Result(c *gin.Context, code int) ginx.Result {
+	return ginx.Result{Code: code, Msg: i18n.Msg(Lang(c), code)}
+}
+
+// success is a successful result carrying the message of msgCode
+func success(c *gin.Context, msgCode int, data any) ginx.Result {
+	return ginx.Result{Code: errs.OK, Msg: i18n.Msg(Lang(c), msgCode), Data: data}
+}
+
+// errResult maps err to its registered code. Errors without a code are system
+// errors and returned to be logged, registered ones are expected outcomes.
+func errResult(c *gin.Context, err error) (ginx.Result, error) {
+	for _, se := range serviceErrs {
+		if errors.Is(err, se.err) {
+			return Result(c, se.code), nil
+		}
+	}
+	return Result(c, errs.Internal), err
+}
@@ -0,0 +1,49 @@
+package web
+
+import (
+	"github.com/cyvqet/connectify/internal/errs"
+	"github.com/cyvqet/connectify/internal/service/sms"
+	"github.com/cyvqet/connectify/internal/service/sms/auth"
+	"github.com/cyvqet/connectify/pkg/ginx"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SMSHandler exposes Connectify's SMS provider stack to other internal teams
+type SMSHandler struct {
+	svc sms.Service
+	w   *ginx.Wrapper
+}
+
+// NewSMSHandler expects svc to be wrapped by auth.Service
+func NewSMSHandler(svc sms.Service, w *ginx.Wrapper) *SMSHandler {
+	return &SMSHandler{
+		svc: svc,
+		w:   w,
+	}
+}
+
+func (h *SMSHandler) RegisterRouter(r *gin.Engine) {
+	ig := r.Group("/internal/sms")
+	ig.POST("/send", ginx.WrapBody(h.w, h.Send))
+}
+
+type SendSMSReq struct {
+	TplId   string   `json:"tplId"`
+	Args    []string `json:"args"`
+	Numbers []string `json:"numbers"`
+}
+
+func (h *SMSHandler) Send(c *gin.Context, req SendSMSReq) (ginx.Result, error) {
+	if req.TplId == "" || len(req.Numbers) == 0 {
+		return Result(c, errs.SMSTemplateRequired), nil
+	}
+
+	// The service token identifies the calling business
+	ctx := auth.WithToken(c.Request.Context(), c.GetHeader("X-Service-Token"))
+	err := h.svc.Send(ctx, req.TplId, req.Args, req.Numbers...)
+	if err != nil {
+		return errResult(c, err)
+	}
+	return success(c, errs.SMSSentOK, nil), nil
+}
@@ -0,0 +1,181 @@
+package web
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/cyvqet/connectify/internal/errs"
+	"github.com/cyvqet/connectify/internal/service"
+	"github.com/cyvqet/connectify/pkg/ginx"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxSMSLogPageSize = 100
+	// maxReceiptSkew bounds how old a signed receipt may be, against replays
+	maxReceiptSkew = 5 * time.Minute
+)
+
+type SMSLogHandler struct {
+	svc           service.SMSLogService
+	receiptSecret []byte
+	w             *ginx.Wrapper
+}
+
+// NewSMSLogHandler accepts the receipts signed with receiptSecret, see VerifyReceipt
+func NewSMSLogHandler(svc service.SMSLogService, receiptSecret []byte, w *ginx.Wrapper) *SMSLogHandler {
+	return &SMSLogHandler{
+		svc:           svc,
+		receiptSecret: receiptSecret,
+		w:             w,
+	}
+}
+
+func (h *SMSLogHandler) RegisterRouter(r *gin.Engine) {
+	// Called by SMS providers
+	r.POST("/sms/receipt", h.VerifyReceipt, ginx.WrapBody(h.w, h.Receipt))
+
+	// Admins only, like every /admin route
+	ag := r.Group("/admin/sms")
+	ag.GET("/logs", h.w.Wrap(h.Logs))
+}
+
+type SMSReceipt struct {
+	MsgId  string `json:"msgId"`
+	Status string `json:"status"` // DELIVERED or FAILED
+	Error  string `json:"error"`
+}
+
+type SMSReceiptReq struct {
+	Provider string       `json:"provider"`
+	Receipts []SMSReceipt `json:"receipts"`
+}
+
+// VerifyReceipt only lets through receipts signed with the receipt secret.
+// X-Signature is the hex HMAC-SHA256 of X-Timestamp (unix seconds), a newline
+// and the body. Receipts older than maxReceiptSkew are rejected.
+func (h *SMSLogHandler) VerifyReceipt(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		ginx.Abort(c, Result(c, errs.InvalidInput))
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	timestamp := c.GetHeader("X-Timestamp")
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(sec, 0)).Abs() > maxReceiptSkew {
+		ginx.Abort(c, Result(c, errs.InvalidReceiptSign))
+		return
+	}
+	sign, err := hex.DecodeString(c.GetHeader("X-Signature"))
+	if err != nil || !hmac.Equal(sign, receiptSign(h.receiptSecret, timestamp, body)) {
+		ginx.Abort(c, Result(c, errs.InvalidReceiptSign))
+		return
+	}
+	c.Next()
+}
+
+func receiptSign(secret []byte, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("\n"))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+func (h *SMSLogHandler) Receipt(c *gin.Context, req SMSReceiptReq) (ginx.Result, error) {
+	for _, rc := range req.Receipts {
+		if rc.Status != "DELIVERED" && rc.Status != "FAILED" {
+			return Result(c, errs.ReceiptStatusUnknown), nil
+		}
+	}
+
+	for _, rc := range req.Receipts {
+		err := h.svc.Receipt(c.Request.Context(), req.Provider, rc.MsgId, rc.Status == "DELIVERED", rc.Error)
+		if err != nil {
+			return Result(c, errs.Internal), err
+		}
+	}
+
+	return Result(c, errs.OK), nil
+}
+
+// Logs queries by phone number and [start, end) in unix milliseconds
+func (h *SMSLogHandler) Logs(c *gin.Context) (ginx.Result, error) {
+	phone := c.Query("phone")
+	if phone == "" {
+		return Result(c, errs.PhoneRequired), nil
+	}
+
+	end := time.Now()
+	start := end.Add(-24 * time.Hour)
+	var err error
+	if v := c.Query("start"); v != "" {
+		if start, err = parseUnixMilli(v); err != nil {
+			return Result(c, errs.InvalidTimeRange), nil
+		}
+	}
+	if v := c.Query("end"); v != "" {
+		if end, err = parseUnixMilli(v); err != nil {
+			return Result(c, errs.InvalidTimeRange), nil
+		}
+	}
+
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 || limit > maxSMSLogPageSize {
+		limit = maxSMSLogPageSize
+	}
+
+	logs, err := h.svc.FindByPhone(c.Request.Context(), phone, start, end, offset, limit)
+	if err != nil {
+		return Result(c, errs.Internal), err
+	}
+
+	type LogVo struct {
+		Id            int64  `json:"id"`
+		Phone         string `json:"phone"`
+		TplId         string `json:"tplId"`
+		Provider      string `json:"provider"`
+		ProviderMsgId string `json:"providerMsgId"`
+		LatencyMs     int64  `json:"latencyMs"`
+		Status        string `json:"status"`
+		Error         string `json:"error"`
+		Ctime         int64  `json:"ctime"`
+		Utime         int64  `json:"utime"`
+	}
+	vos := make([]LogVo, 0, len(logs))
+	for _, l := range logs {
+		vos = append(vos, LogVo{
+			Id:            l.Id,
+			Phone:         l.Phone,
+			TplId:         l.TplId,
+			Provider:      l.Provider,
+			ProviderMsgId: l.ProviderMsgId,
+			LatencyMs:     l.Latency.Milliseconds(),
+			Status:        l.Status.String(),
+			Error:         l.ErrMsg,
+			Ctime:         l.Ctime.UnixMilli(),
+			Utime:         l.Utime.UnixMilli(),
+		})
+	}
+	return success(c, errs.OK, vos), nil
+}
+
+func parseUnixMilli(v string) (time.Time, error) {
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(ms), nil
+}
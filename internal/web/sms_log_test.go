@@ -0,0 +1,193 @@
+package web
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cyvqet/connectify/internal/domain"
+	"github.com/cyvqet/connectify/internal/service"
+	svcmocks "github.com/cyvqet/connectify/internal/service/mocks"
+	"github.com/cyvqet/connectify/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestSMSLogHandler_Receipt(t *testing.T) {
+	secret := []byte("test-receipt-secret")
+	body := `{"provider":"tencent","receipts":[{"msgId":"msg-1","status":"DELIVERED"}]}`
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	sign := func(timestamp, body string) string {
+		return hex.EncodeToString(receiptSign(secret, timestamp, []byte(body)))
+	}
+
+	testCases := []struct {
+		name      string
+		mock      func(ctrl *gomock.Controller) service.SMSLogService
+		body      string
+		timestamp string
+		signature string
+		wantCode  int
+		wantBody  string
+	}{
+		{
+			name: "signed",
+			mock: func(ctrl *gomock.Controller) service.SMSLogService {
+				svc := svcmocks.NewMockSMSLogService(ctrl)
+				svc.EXPECT().Receipt(gomock.Any(), "tencent", "msg-1", true, "").Return(nil)
+				return svc
+			},
+			body:      body,
+			timestamp: now,
+			signature: sign(now, body),
+			wantCode:  http.StatusOK,
+			wantBody:  `{"code":0,"msg":"ok"}`,
+		},
+		{
+			name: "unsigned",
+			mock: func(ctrl *gomock.Controller) service.SMSLogService {
+				return svcmocks.NewMockSMSLogService(ctrl)
+			},
+			body:      body,
+			timestamp: now,
+			wantCode:  http.StatusUnauthorized,
+			wantBody:  `{"code":401003,"msg":"invalid receipt signature"}`,
+		},
+		{
+			name: "signed with another secret",
+			mock: func(ctrl *gomock.Controller) service.SMSLogService {
+				return svcmocks.NewMockSMSLogService(ctrl)
+			},
+			body:      body,
+			timestamp: now,
+			signature: hex.EncodeToString(receiptSign([]byte("forged"), now, []byte(body))),
+			wantCode:  http.StatusUnauthorized,
+			wantBody:  `{"code":401003,"msg":"invalid receipt signature"}`,
+		},
+		{
+			name: "body changed after signing",
+			mock: func(ctrl *gomock.Controller) service.SMSLogService {
+				return svcmocks.NewMockSMSLogService(ctrl)
+			},
+			body:      `{"provider":"tencent","receipts":[{"msgId":"msg-2","status":"DELIVERED"}]}`,
+			timestamp: now,
+			signature: sign(now, body),
+			wantCode:  http.StatusUnauthorized,
+			wantBody:  `{"code":401003,"msg":"invalid receipt signature"}`,
+		},
+		{
+			name: "replayed",
+			mock: func(ctrl *gomock.Controller) service.SMSLogService {
+				return svcmocks.NewMockSMSLogService(ctrl)
+			},
+			body:      body,
+			timestamp: stale,
+			signature: sign(stale, body),
+			wantCode:  http.StatusUnauthorized,
+			wantBody:  `{"code":401003,"msg":"invalid receipt signature"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			handler := NewSMSLogHandler(tc.mock(ctrl), secret, NewWrapper(logger.NewNopLogger()))
+			gin.SetMode(gin.TestMode)
+			server := gin.New()
+			handler.RegisterRouter(server)
+
+			req := httptest.NewRequest(http.MethodPost, "/sms/receipt", bytes.NewReader([]byte(tc.body)))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Timestamp", tc.timestamp)
+			if tc.signature != "" {
+				req.Header.Set("X-Signature", tc.signature)
+			}
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.JSONEq(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
+
+func TestSMSLogHandler_Logs(t *testing.T) {
+	start, end := time.UnixMilli(1_700_000_000_000), time.UnixMilli(1_700_086_400_000)
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) service.SMSLogService
+		query    string
+		wantBody string
+	}{
+		{
+			name: "filtered",
+			mock: func(ctrl *gomock.Controller) service.SMSLogService {
+				svc := svcmocks.NewMockSMSLogService(ctrl)
+				svc.EXPECT().FindByPhone(gomock.Any(), "13800135678", start, end, 20, 10).
+					Return([]domain.SMSLog{{
+						Id: 1, Phone: "138****5678", TplId: "tpl-1", Provider: "tencent",
+						ProviderMsgId: "msg-1", Latency: 120 * time.Millisecond,
+						Status: domain.SMSStatusDelivered, Ctime: start, Utime: end,
+					}}, nil)
+				return svc
+			},
+			query: "phone=13800135678&start=1700000000000&end=1700086400000&offset=20&limit=10",
+			wantBody: `{"code":0,"msg":"ok","data":[{"id":1,"phone":"138****5678","tplId":"tpl-1",
+				"provider":"tencent","providerMsgId":"msg-1","latencyMs":120,"status":"DELIVERED",
+				"error":"","ctime":1700000000000,"utime":1700086400000}]}`,
+		},
+		{
+			name: "page size capped",
+			mock: func(ctrl *gomock.Controller) service.SMSLogService {
+				svc := svcmocks.NewMockSMSLogService(ctrl)
+				svc.EXPECT().FindByPhone(gomock.Any(), "13800135678", gomock.Any(), gomock.Any(), 0, maxSMSLogPageSize).
+					Return(nil, nil)
+				return svc
+			},
+			query:    "phone=13800135678&offset=-1&limit=1000",
+			wantBody: `{"code":0,"msg":"ok","data":[]}`,
+		},
+		{
+			name: "no phone",
+			mock: func(ctrl *gomock.Controller) service.SMSLogService {
+				return svcmocks.NewMockSMSLogService(ctrl)
+			},
+			wantBody: `{"code":400004,"msg":"please input phone number"}`,
+		},
+		{
+			name: "bad start",
+			mock: func(ctrl *gomock.Controller) service.SMSLogService {
+				return svcmocks.NewMockSMSLogService(ctrl)
+			},
+			query:    "phone=13800135678&start=yesterday",
+			wantBody: `{"code":400010,"msg":"invalid start or end"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			handler := NewSMSLogHandler(tc.mock(ctrl), []byte("test-receipt-secret"), NewWrapper(logger.NewNopLogger()))
+			gin.SetMode(gin.TestMode)
+			server := gin.New()
+			handler.RegisterRouter(server)
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/sms/logs?"+tc.query, nil)
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.JSONEq(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
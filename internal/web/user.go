@@ -1,14 +1,13 @@
 package web
 
 import (
-	"errors"
-	"log"
-	"net/http"
 	"time"
 
 	"github.com/cyvqet/connectify/internal/domain"
-	"github.com/cyvqet/connectify/internal/repository/cache"
+	"github.com/cyvqet/connectify/internal/errs"
+	"github.com/cyvqet/connectify/internal/i18n"
 	"github.com/cyvqet/connectify/internal/service"
+	"github.com/cyvqet/connectify/pkg/ginx"
 
 	"github.com/dlclark/regexp2"
 	"github.com/gin-contrib/sessions"
@@ -20,6 +19,8 @@ type UserClaims struct {
 	UserId    int64
 	UserEmail string
 	UserAgent string
+	// Locale is the user's language preference, see Lang
+	Locale string
 	jwt.RegisteredClaims
 }
 
@@ -31,104 +32,81 @@ const (
 type UserHandler struct {
 	svc     service.UserService
 	codeSvc service.CodeService
+	jwtHdl  *JWTHandler
+	w       *ginx.Wrapper
 }
 
-func NewUserHandler(svc service.UserService, codeSvc service.CodeService) *UserHandler {
+func NewUserHandler(svc service.UserService, codeSvc service.CodeService, jwtHdl *JWTHandler,
+	w *ginx.Wrapper) *UserHandler {
 	return &UserHandler{
 		svc:     svc,
 		codeSvc: codeSvc,
+		jwtHdl:  jwtHdl,
+		w:       w,
 	}
 }
 
 func (u *UserHandler) RegisterRouter(r *gin.Engine) {
 	ug := r.Group("/user")
 
-	ug.POST("/signup", u.Signup)
-	ug.POST("/login", u.Login)
-	ug.POST("/login_jwt", u.LoginJwt)
-	ug.POST("/logout", u.Logout)
-	ug.POST("/profile", u.Profile)
+	ug.POST("/signup", ginx.WrapBody(u.w, u.Signup))
+	ug.POST("/login", ginx.WrapBody(u.w, u.Login))
+	ug.POST("/login_jwt", ginx.WrapBody(u.w, u.LoginJwt))
+	ug.POST("/logout", u.w.Wrap(u.Logout))
+	ug.POST("/profile", ginx.WrapBody(u.w, u.Profile))
+	ug.POST("/locale", ginx.WrapBody(u.w, u.SetLocale))
 
-	ug.POST("/send_sms_code", u.SendSMSLoginCode)
-	ug.POST("/login_sms", u.LoginSMS)
+	ug.POST("/send_sms_code", ginx.WrapBody(u.w, u.SendSMSLoginCode))
+	ug.POST("/login_sms", ginx.WrapBody(u.w, u.LoginSMS))
 }
 
-func (u *UserHandler) Signup(c *gin.Context) {
-	type SignupReq struct {
-		Email           string `json:"email"`
-		Password        string `json:"password"`
-		ConfirmPassword string `json:"confirmPassword"`
-	}
-
-	var req SignupReq
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
-		return
-	}
+type SignupReq struct {
+	Email           string `json:"email"`
+	Password        string `json:"password"`
+	ConfirmPassword string `json:"confirmPassword"`
+}
 
+func (u *UserHandler) Signup(c *gin.Context, req SignupReq) (ginx.Result, error) {
 	ok, err := ValidateEmail(req.Email)
 	if err != nil {
-		log.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "system error"})
-		return
+		return Result(c, errs.Internal), err
 	}
 	if !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "email format error"})
-		return
+		return Result(c, errs.EmailFormat), nil
 	}
 
 	ok, err = ValidatePassword(req.Password)
 	if err != nil {
-		log.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "system error"})
-		return
+		return Result(c, errs.Internal), err
 	}
 	if !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "password format error"})
-		return
+		return Result(c, errs.PasswordFormat), nil
 	}
 
 	if req.Password != req.ConfirmPassword {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "two input passwords are not consistent"})
-		return
+		return Result(c, errs.PasswordMismatch), nil
 	}
 
 	err = u.svc.SignUp(c.Request.Context(), domain.User{
 		Email:    req.Email,
 		Password: req.Password,
 	})
-	if errors.Is(err, service.ErrUserDuplicateEmail) {
-		c.JSON(http.StatusOK, gin.H{"message": "email conflict"})
-		return
-	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "system error"})
-		return
+		return errResult(c, err)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "registration successful"})
+	return success(c, errs.SignupOK, nil), nil
 }
 
-func (u *UserHandler) Login(c *gin.Context) {
-	type LoginReq struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
-	}
-
-	var req LoginReq
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
-		return
-	}
+type LoginReq struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
 
-	err := u.svc.Login(c.Request.Context(), req.Email, req.Password)
+func (u *UserHandler) Login(c *gin.Context, req LoginReq) (ginx.Result, error) {
+	_, err := u.svc.Login(c.Request.Context(), req.Email, req.Password)
 	if err != nil {
-		if errors.Is(err, service.ErrInvaildUserOrPassword) {
-			c.JSON(http.StatusOK, gin.H{"message": "username/password error"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "system error"})
-		return
+		return errResult(c, err)
 	}
 
 	session := sessions.Default(c)      // Get current request session
@@ -138,75 +116,86 @@ func (u *UserHandler) Login(c *gin.Context) {
 	})
 	err = session.Save() // Save session
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "system error"})
-		return
+		return Result(c, errs.Internal), err
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "login successful"})
+	return success(c, errs.LoginOK, nil), nil
 }
 
-func (u *UserHandler) LoginJwt(c *gin.Context) {
-	type LoginJwtReq struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
-	}
-
-	var req LoginJwtReq
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
-		return
-	}
-
-	err := u.svc.Login(c.Request.Context(), req.Email, req.Password)
+func (u *UserHandler) LoginJwt(c *gin.Context, req LoginReq) (ginx.Result, error) {
+	user, err := u.svc.Login(c.Request.Context(), req.Email, req.Password)
 	if err != nil {
-		if errors.Is(err, service.ErrInvaildUserOrPassword) {
-			c.JSON(http.StatusOK, gin.H{"message": "username/password error"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "system error"})
-		return
+		return errResult(c, err)
 	}
 
-	u.SetJWTToken(c, domain.User{Email: req.Email})
+	if err := u.SetJWTToken(c, user); err != nil {
+		return Result(c, errs.Internal), err
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "login successful"})
+	return success(c, errs.LoginOK, nil), nil
 }
 
-func (u *UserHandler) Logout(c *gin.Context) {
+func (u *UserHandler) Logout(c *gin.Context) (ginx.Result, error) {
 	session := sessions.Default(c)
 	session.Options(sessions.Options{
 		MaxAge: -1,
 	})
 	err := session.Save()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "system error"})
-		return
+		return Result(c, errs.Internal), err
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "logout successful"})
+	return success(c, errs.LogoutOK, nil), nil
 }
 
-func (u *UserHandler) Profile(c *gin.Context) {
+type ProfileReq struct {
+	Id int64 `json:"id"`
+}
 
-	type ProfileReq struct {
-		Id int64 `json:"id"`
+func (u *UserHandler) Profile(c *gin.Context, req ProfileReq) (ginx.Result, error) {
+	user, err := u.svc.Profile(c.Request.Context(), req.Id)
+	if err != nil {
+		return errResult(c, err)
 	}
 
-	var req ProfileReq
-	if err := c.Bind(&req); err != nil {
-		return
+	type ProfileVo struct {
+		Id     int64  `json:"id"`
+		Email  string `json:"email"`
+		Locale string `json:"locale"`
 	}
+	return success(c, errs.OK, ProfileVo{
+		Id:     user.Id,
+		Email:  user.Email,
+		Locale: user.Locale,
+	}), nil
+}
 
-	user, err := u.svc.Profile(c.Request.Context(), req.Id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "system error"})
-		return
+type SetLocaleReq struct {
+	Locale string `json:"locale"`
+}
+
+// SetLocale stores the language preference of the logged in user and
+// reissues the token so it applies to the following requests
+func (u *UserHandler) SetLocale(c *gin.Context, req SetLocaleReq) (ginx.Result, error) {
+	lang, ok := i18n.Parse(req.Locale)
+	if !ok {
+		return Result(c, errs.UnsupportedLocale), nil
+	}
+	claims, ok := userClaims(c)
+	if !ok {
+		return Result(c, errs.Unauthorized), nil
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"id":    user.Id,
-		"email": user.Email,
-	})
+	if err := u.svc.SetLocale(c.Request.Context(), claims.UserId, string(lang)); err != nil {
+		return errResult(c, err)
+	}
+
+	claims.Locale = string(lang)
+	c.Set("claim", claims)
+	if err := u.SetJWTToken(c, domain.User{Id: claims.UserId, Email: claims.UserEmail, Locale: claims.Locale}); err != nil {
+		return Result(c, errs.Internal), err
+	}
+	return success(c, errs.LocaleSetOK, nil), nil
 }
 
 func ValidatePassword(password string) (bool, error) {
@@ -219,116 +208,88 @@ func ValidateEmail(email string) (bool, error) {
 	return re.MatchString(email)
 }
 
-func (u *UserHandler) LoginSMS(c *gin.Context) {
-	type Req struct {
-		Phone string `json:"phone"`
-		Code  string `json:"code"`
-	}
-	var req Req
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
-		return
-	}
+type LoginSMSReq struct {
+	Phone string `json:"phone"`
+	Code  string `json:"code"`
+}
 
+func (u *UserHandler) LoginSMS(c *gin.Context, req LoginSMSReq) (ginx.Result, error) {
 	ok, err := u.codeSvc.Verify(c.Request.Context(), "bizLogin", req.Phone, req.Code)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "system error",
-		})
-		return
+		return errResult(c, err)
 	}
 	if !ok {
-		c.JSON(http.StatusOK, gin.H{
-			"message": "verification code is incorrect or expired, please get it again",
-		})
-		return
+		return Result(c, errs.CodeIncorrect), nil
 	}
 
 	// Find or create user (by phone number)
 	user, err := u.svc.FindOrCreate(c.Request.Context(), req.Phone)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "system error",
-		})
-		return
+		return Result(c, errs.Internal), err
 	}
 
-	u.SetJWTToken(c, user)
+	if err := u.SetJWTToken(c, user); err != nil {
+		return Result(c, errs.Internal), err
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "login successful"})
+	return success(c, errs.LoginOK, nil), nil
 }
 
+// MustGetUserClaims reads the claims stored by the JWT middleware,
+// the request is aborted if there are none
 func (u *UserHandler) MustGetUserClaims(c *gin.Context) UserClaims {
-	// Get user information from claim stored in context by middleware
-	claimAny, exists := c.Get("claim")
-	if !exists {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "system error"})
-		c.Abort()
-		return UserClaims{}
-	}
-
-	claim, ok := claimAny.(UserClaims)
+	claim, ok := userClaims(c)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "system error"})
-		c.Abort()
+		ginx.Abort(c, Result(c, errs.Internal))
 		return UserClaims{}
 	}
-
 	return claim
 }
 
-func (u *UserHandler) SetJWTToken(c *gin.Context, user domain.User) {
+// userClaims gets user information from claim stored in context by middleware
+func userClaims(c *gin.Context) (UserClaims, bool) {
+	claimAny, exists := c.Get("claim")
+	if !exists {
+		return UserClaims{}, false
+	}
+	claim, ok := claimAny.(UserClaims)
+	return claim, ok
+}
+
+func (u *UserHandler) SetJWTToken(c *gin.Context, user domain.User) error {
 	claims := UserClaims{
 		UserId:    user.Id,
 		UserEmail: user.Email,
 		UserAgent: c.Request.UserAgent(),
+		Locale:    user.Locale,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(30 * time.Minute)),
 		},
 	}
+	return u.jwtHdl.SetToken(c, claims)
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenStr, err := token.SignedString([]byte("secret"))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "system error"})
-		c.Abort()
-		return
-	}
-
-	c.Header("Jwt-Token", tokenStr)
+type SendSMSCodeReq struct {
+	Phone string `json:"phone"`
+	// sms (default) or voice, voice reads out the code already sent by sms
+	Channel string `json:"channel"`
 }
 
-func (u *UserHandler) SendSMSLoginCode(c *gin.Context) {
-	type Req struct {
-		Phone string `json:"phone"`
-	}
-	var req Req
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
-		return
+func (u *UserHandler) SendSMSLoginCode(c *gin.Context, req SendSMSCodeReq) (ginx.Result, error) {
+	if req.Phone == "" {
+		return Result(c, errs.PhoneRequired), nil
 	}
 
-	if req.Phone == "" {
-		c.JSON(http.StatusOK, gin.H{
-			"message": "please input phone number",
-		})
-		return
+	channel := service.CodeChannelSMS
+	if req.Channel != "" {
+		channel = service.CodeChannel(req.Channel)
 	}
 
-	verificationCode, err := u.codeSvc.Send(c.Request.Context(), "bizLogin", req.Phone)
-	switch {
-	case err == nil:
-		c.JSON(http.StatusOK, gin.H{
-			"message": "send successful",
-			"code":    verificationCode, // TODO: Remove this line in production environment
-		})
-	case errors.Is(err, cache.ErrVerificationCodeSendRateLimited):
-		c.JSON(http.StatusOK, gin.H{
-			"message": "sms send too frequently, please try again later",
-		})
-	default:
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "system error",
-		})
+	// The code is sent with the template of the user's language
+	ctx := i18n.WithLang(c.Request.Context(), Lang(c))
+	if err := u.codeSvc.Send(ctx, "bizLogin", req.Phone, c.ClientIP(), channel); err != nil {
+		return errResult(c, err)
 	}
+	// The code only reaches the phone, never the response
+	return success(c, errs.CodeSentOK, nil), nil
 }
@@ -3,6 +3,7 @@ package web
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -11,6 +12,7 @@ import (
 	"github.com/cyvqet/connectify/internal/domain"
 	"github.com/cyvqet/connectify/internal/service"
 	svcmocks "github.com/cyvqet/connectify/internal/service/mocks"
+	"github.com/cyvqet/connectify/pkg/logger"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -49,7 +51,7 @@ func TestUserHandler_Signup(t *testing.T) {
 				return req
 			},
 			wantCode: http.StatusOK,
-			wantBody: `{"message":"registration successful"}`,
+			wantBody: `{"code":0,"msg":"registration successful"}`,
 		},
 		{
 			name: "bind error",
@@ -69,7 +71,7 @@ func TestUserHandler_Signup(t *testing.T) {
 				return req
 			},
 			wantCode: http.StatusBadRequest,
-			wantBody: `{"message":"invalid request"}`,
+			wantBody: `{"code":400000,"msg":"invalid request"}`,
 		},
 		{
 			name: "email format error",
@@ -90,7 +92,7 @@ func TestUserHandler_Signup(t *testing.T) {
 				return req
 			},
 			wantCode: http.StatusBadRequest,
-			wantBody: `{"message":"email format error"}`,
+			wantBody: `{"code":400001,"msg":"email format error"}`,
 		},
 		{
 			name: "password format error",
@@ -111,7 +113,7 @@ func TestUserHandler_Signup(t *testing.T) {
 				return req
 			},
 			wantCode: http.StatusBadRequest,
-			wantBody: `{"message":"password format error"}`,
+			wantBody: `{"code":400002,"msg":"password format error"}`,
 		},
 		{
 			name: "two input passwords are not consistent",
@@ -132,7 +134,7 @@ func TestUserHandler_Signup(t *testing.T) {
 				return req
 			},
 			wantCode: http.StatusBadRequest,
-			wantBody: `{"message":"two input passwords are not consistent"}`,
+			wantBody: `{"code":400003,"msg":"two input passwords are not consistent"}`,
 		},
 		{
 			name: "email conflict",
@@ -156,8 +158,8 @@ func TestUserHandler_Signup(t *testing.T) {
 				assert.NoError(t, err)
 				return req
 			},
-			wantCode: http.StatusOK,
-			wantBody: `{"message":"email conflict"}`,
+			wantCode: http.StatusConflict,
+			wantBody: `{"code":409001,"msg":"email conflict"}`,
 		},
 		{
 			name: "system error",
@@ -182,7 +184,7 @@ func TestUserHandler_Signup(t *testing.T) {
 				return req
 			},
 			wantCode: http.StatusInternalServerError,
-			wantBody: `{"message":"system error"}`,
+			wantBody: `{"code":500000,"msg":"system error"}`,
 		},
 	}
 
@@ -193,7 +195,7 @@ func TestUserHandler_Signup(t *testing.T) {
 
 			// build handler
 			userSvc, codeSvc := tc.mock(ctrl)
-			handler := NewUserHandler(userSvc, codeSvc)
+			handler := NewUserHandler(userSvc, codeSvc, NewJWTHandler([]byte("secret")), NewWrapper(logger.NewNopLogger()))
 
 			// prepare server, register routes
 			gin.SetMode(gin.TestMode)
@@ -317,7 +319,7 @@ func TestUserHandler_LoginJwt(t *testing.T) {
 				return req
 			},
 			wantCode:  http.StatusBadRequest,
-			wantBody:  `{"message":"invalid request"}`,
+			wantBody:  `{"code":400000,"msg":"invalid request"}`,
 			wantToken: false,
 		},
 		{
@@ -326,7 +328,7 @@ func TestUserHandler_LoginJwt(t *testing.T) {
 				userSvc := svcmocks.NewMockUserService(ctrl)
 				userSvc.EXPECT().
 					Login(gomock.Any(), "test@example.com", "wrong").
-					Return(service.ErrInvaildUserOrPassword)
+					Return(domain.User{}, service.ErrInvaildUserOrPassword)
 				return userSvc, svcmocks.NewMockCodeService(ctrl)
 			},
 			reqBuilder: func(t *testing.T) *http.Request {
@@ -336,8 +338,8 @@ func TestUserHandler_LoginJwt(t *testing.T) {
 				req.Header.Set("Content-Type", "application/json")
 				return req
 			},
-			wantCode:  http.StatusOK,
-			wantBody:  `{"message":"username/password error"}`,
+			wantCode:  http.StatusUnauthorized,
+			wantBody:  `{"code":401001,"msg":"username/password error"}`,
 			wantToken: false,
 		},
 		{
@@ -346,7 +348,7 @@ func TestUserHandler_LoginJwt(t *testing.T) {
 				userSvc := svcmocks.NewMockUserService(ctrl)
 				userSvc.EXPECT().
 					Login(gomock.Any(), "test@example.com", "Test@1234").
-					Return(errors.New("db error"))
+					Return(domain.User{}, errors.New("db error"))
 				return userSvc, svcmocks.NewMockCodeService(ctrl)
 			},
 			reqBuilder: func(t *testing.T) *http.Request {
@@ -357,7 +359,7 @@ func TestUserHandler_LoginJwt(t *testing.T) {
 				return req
 			},
 			wantCode:  http.StatusInternalServerError,
-			wantBody:  `{"message":"system error"}`,
+			wantBody:  `{"code":500000,"msg":"system error"}`,
 			wantToken: false,
 		},
 		{
@@ -366,7 +368,7 @@ func TestUserHandler_LoginJwt(t *testing.T) {
 				userSvc := svcmocks.NewMockUserService(ctrl)
 				userSvc.EXPECT().
 					Login(gomock.Any(), "test@example.com", "Test@1234").
-					Return(nil)
+					Return(domain.User{Id: 1, Email: "test@example.com", Locale: "zh-CN"}, nil)
 				return userSvc, svcmocks.NewMockCodeService(ctrl)
 			},
 			reqBuilder: func(t *testing.T) *http.Request {
@@ -378,7 +380,7 @@ func TestUserHandler_LoginJwt(t *testing.T) {
 				return req
 			},
 			wantCode:  http.StatusOK,
-			wantBody:  `{"message":"login successful"}`,
+			wantBody:  `{"code":0,"msg":"login successful"}`,
 			wantToken: true,
 			checkToken: func(t *testing.T, tokenStr string, req *http.Request) {
 				t.Helper()
@@ -390,8 +392,9 @@ func TestUserHandler_LoginJwt(t *testing.T) {
 				assert.NoError(t, err)
 				assert.True(t, parsed.Valid)
 
-				assert.Equal(t, int64(0), claims.UserId)
+				assert.Equal(t, int64(1), claims.UserId)
 				assert.Equal(t, "test@example.com", claims.UserEmail)
+				assert.Equal(t, "zh-CN", claims.Locale)
 				assert.Equal(t, req.UserAgent(), claims.UserAgent)
 
 				if assert.NotNil(t, claims.ExpiresAt) {
@@ -410,7 +413,7 @@ func TestUserHandler_LoginJwt(t *testing.T) {
 			defer ctrl.Finish()
 
 			userSvc, codeSvc := tc.mock(ctrl)
-			handler := NewUserHandler(userSvc, codeSvc)
+			handler := NewUserHandler(userSvc, codeSvc, NewJWTHandler([]byte("secret")), NewWrapper(logger.NewNopLogger()))
 
 			gin.SetMode(gin.TestMode)
 			server := gin.New()
@@ -436,3 +439,154 @@ func TestUserHandler_LoginJwt(t *testing.T) {
 		})
 	}
 }
+
+func TestUserHandler_LoginSMS(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) (service.UserService, service.CodeService)
+		lang     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name: "login successful",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService) {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), "bizLogin", "13800138000", "123456").Return(true, nil)
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				userSvc.EXPECT().FindOrCreate(gomock.Any(), "13800138000").Return(domain.User{Id: 1}, nil)
+				return userSvc, codeSvc
+			},
+			wantCode: http.StatusOK,
+			wantBody: `{"code":0,"msg":"login successful"}`,
+		},
+		{
+			name: "code incorrect",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService) {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), "bizLogin", "13800138000", "123456").Return(false, nil)
+				return svcmocks.NewMockUserService(ctrl), codeSvc
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":400006,"msg":"verification code is incorrect, please check and try again"}`,
+		},
+		{
+			name: "code expired",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService) {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), "bizLogin", "13800138000", "123456").
+					Return(false, service.ErrCodeExpired)
+				return svcmocks.NewMockUserService(ctrl), codeSvc
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":400007,"msg":"verification code has expired, please get a new one"}`,
+		},
+		{
+			name: "too many attempts",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService) {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), "bizLogin", "13800138000", "123456").
+					Return(false, service.ErrCodeTooManyAttempts)
+				return svcmocks.NewMockUserService(ctrl), codeSvc
+			},
+			wantCode: http.StatusTooManyRequests,
+			wantBody: `{"code":429002,"msg":"too many incorrect attempts, please get a new verification code"}`,
+		},
+		{
+			name: "sms login unavailable",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService) {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), "bizLogin", "13800138000", "123456").
+					Return(false, service.ErrSMSLoginUnavailable)
+				return svcmocks.NewMockUserService(ctrl), codeSvc
+			},
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: `{"code":503001,"msg":"sms login is temporarily unavailable, please log in with email"}`,
+		},
+		{
+			name: "code incorrect in chinese",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService) {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), "bizLogin", "13800138000", "123456").Return(false, nil)
+				return svcmocks.NewMockUserService(ctrl), codeSvc
+			},
+			lang:     "zh-CN,zh;q=0.9,en;q=0.8",
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"code":400006,"msg":"验证码错误，请检查后重试"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			userSvc, codeSvc := tc.mock(ctrl)
+			handler := NewUserHandler(userSvc, codeSvc, NewJWTHandler([]byte("secret")), NewWrapper(logger.NewNopLogger()))
+
+			gin.SetMode(gin.TestMode)
+			server := gin.New()
+			handler.RegisterRouter(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/user/login_sms",
+				bytes.NewReader([]byte(`{"phone":"13800138000","code":"123456"}`)))
+			assert.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept-Language", tc.lang)
+			rec := httptest.NewRecorder()
+
+			server.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.wantCode, rec.Code)
+			assert.JSONEq(t, tc.wantBody, rec.Body.String())
+		})
+	}
+}
+
+func TestUserHandler_SendSMSLoginCode(t *testing.T) {
+	testCases := []struct {
+		name     string
+		sendErr  error
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "sent",
+			wantCode: http.StatusOK,
+			wantBody: `{"code":0,"msg":"send successful"}`,
+		},
+		{
+			// As the service wraps it when the quota cannot be checked
+			name:     "redis down",
+			sendErr:  fmt.Errorf("send quota check failed: %w", service.ErrSMSLoginUnavailable),
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: `{"code":503001,"msg":"sms login is temporarily unavailable, please log in with email"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			codeSvc := svcmocks.NewMockCodeService(ctrl)
+			codeSvc.EXPECT().Send(gomock.Any(), "bizLogin", "13800138000", gomock.Any(), service.CodeChannelSMS).
+				Return(tc.sendErr)
+			handler := NewUserHandler(svcmocks.NewMockUserService(ctrl), codeSvc,
+				NewJWTHandler([]byte("secret")), NewWrapper(logger.NewNopLogger()))
+
+			gin.SetMode(gin.TestMode)
+			server := gin.New()
+			handler.RegisterRouter(server)
+
+			req := httptest.NewRequest(http.MethodPost, "/user/send_sms_code",
+				bytes.NewReader([]byte(`{"phone":"13800138000"}`)))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.wantCode, rec.Code)
+			assert.JSONEq(t, tc.wantBody, rec.Body.String())
+		})
+	}
+}
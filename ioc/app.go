@@ -0,0 +1,139 @@
+package ioc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cyvqet/connectify/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+// Shutdown phases, run in this order once the server has drained
+const (
+	// phaseWorkers stops background goroutines still using the clients
+	phaseWorkers = iota
+	// phaseClients closes the Redis client and the database pool
+	phaseClients
+	// phaseTelemetry flushes spans and logs, last so the shutdown itself is recorded
+	phaseTelemetry
+)
+
+type shutdownHook struct {
+	phase int
+	name  string
+	fn    func(ctx context.Context) error
+}
+
+var (
+	shutdownMu    sync.Mutex
+	shutdownHooks []shutdownHook
+)
+
+// onShutdown runs fn when the app stops. Hooks of a phase run in reverse
+// registration order, like defer.
+func onShutdown(phase int, name string, fn func(ctx context.Context) error) {
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+	shutdownHooks = append(shutdownHooks, shutdownHook{phase: phase, name: name, fn: fn})
+}
+
+// App is the HTTP server together with everything it has to release on exit
+type App struct {
+	Server *http.Server
+	// ShutdownTimeout bounds draining plus the shutdown hooks
+	ShutdownTimeout time.Duration
+	l               logger.Logger
+}
+
+func InitApp(engine *gin.Engine, l logger.Logger) *App {
+	cfg := defaultConfig().Server
+	err := viper.UnmarshalKey("server", &cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	return &App{
+		Server: &http.Server{
+			Addr:              cfg.Addr,
+			Handler:           engine,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			ReadTimeout:       cfg.ReadTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+			MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		},
+		ShutdownTimeout: cfg.ShutdownTimeout,
+		l:               l,
+	}
+}
+
+// Run serves until SIGINT or SIGTERM, then shuts down gracefully.
+// It only returns an error if the server could not start or stop cleanly.
+func (a *App) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		a.l.Info("http server listening", logger.String("addr", a.Server.Addr))
+		errCh <- a.Server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		// Failed to listen, still release what was set up
+		return errors.Join(err, a.Shutdown(context.Background()))
+	case <-ctx.Done():
+	}
+	// A second signal kills the process right away
+	stop()
+
+	a.l.Info("shutting down", logger.Duration("timeout", a.ShutdownTimeout))
+	return a.Shutdown(context.Background())
+}
+
+// Shutdown stops accepting connections, waits for in-flight requests and
+// then runs the shutdown hooks phase by phase, all within ShutdownTimeout
+func (a *App) Shutdown(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, a.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	if err := a.Server.Shutdown(ctx); err != nil {
+		a.l.Error("drain http server failed", logger.Error(err))
+		errs = append(errs, err)
+	}
+
+	shutdownMu.Lock()
+	hooks := make([]shutdownHook, len(shutdownHooks))
+	for i, hook := range shutdownHooks {
+		hooks[len(hooks)-1-i] = hook
+	}
+	shutdownMu.Unlock()
+	sort.SliceStable(hooks, func(i, j int) bool {
+		return hooks[i].phase < hooks[j].phase
+	})
+
+	flushing := false
+	for _, hook := range hooks {
+		if hook.phase == phaseTelemetry && !flushing {
+			// Last entry that surely reaches the sinks
+			a.l.Info("released resources, flushing telemetry", logger.Int("errors", len(errs)))
+			flushing = true
+		}
+		if err := hook.fn(ctx); err != nil {
+			// The logger may be flushed already, the error is returned too
+			a.l.Error("shutdown hook failed", logger.String("hook", hook.name), logger.Error(err))
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
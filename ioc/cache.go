@@ -0,0 +1,67 @@
+package ioc
+
+import (
+	"fmt"
+
+	"github.com/cyvqet/connectify/internal/repository"
+	"github.com/cyvqet/connectify/internal/repository/cache"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+// InitSMSQuotaCache layered send quotas that protect against SMS pumping
+func InitSMSQuotaCache(redisClient redis.Cmdable) cache.SMSQuotaCache {
+	var cfg SMSQuotaConfig
+	err := viper.UnmarshalKey("sms.quota", &cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	return cache.NewSMSQuotaCache(redisClient, cache.SMSQuota{
+		PhoneHourly: cfg.PhoneHourly,
+		PhoneDaily:  cfg.PhoneDaily,
+		IPDaily:     cfg.IPDaily,
+		BizDaily:    cfg.BizDaily,
+	})
+}
+
+// InitUserCache user info cache with jittered ttl and short-lived not-found entries
+func InitUserCache(redisClient redis.Cmdable, reg prometheus.Registerer) cache.UserCache {
+	cfg := defaultConfig().Cache.User
+	err := viper.UnmarshalKey("cache.user", &cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	return cache.NewMetricsUserCache(cache.NewUserCache(redisClient, cache.UserCacheConfig{
+		Expire:         cfg.Expire,
+		Jitter:         cfg.Jitter,
+		NotFoundExpire: cfg.NotFoundExpire,
+	}), reg)
+}
+
+// CodeStore is the verification code cache with the Health it depends on
+type CodeStore struct {
+	Cache  cache.CodeCache
+	Health cache.Health
+}
+
+// InitCodeStore picks the verification code store from code.cache.
+// memory keeps codes per process, only use it with a single instance. It keeps
+// working while Redis is down, the send quotas still need Redis though.
+func InitCodeStore(redisClient redis.Cmdable, health cache.Health) CodeStore {
+	switch backend := viper.GetString("code.cache"); backend {
+	case "", "redis":
+		return CodeStore{Cache: cache.NewCodeCache(redisClient), Health: health}
+	case "memory":
+		return CodeStore{Cache: cache.NewMemoryCodeCache(), Health: cache.AlwaysHealthy{}}
+	default:
+		panic(fmt.Sprintf("unknown code cache backend %q", backend))
+	}
+}
+
+func InitCodeRepository(store CodeStore) repository.CodeRepository {
+	return repository.NewCodeRepository(store.Cache, store.Health)
+}
@@ -0,0 +1,52 @@
+package ioc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cyvqet/connectify/internal/repository"
+	"github.com/cyvqet/connectify/internal/service"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type redisDown struct{}
+
+func (redisDown) Healthy() bool {
+	return false
+}
+
+func TestInitCodeRepository_RedisDown(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	testCases := []struct {
+		name    string
+		backend string
+		wantErr error
+	}{
+		{name: "redis", backend: "redis", wantErr: repository.ErrCodeUnavailable},
+		{name: "memory ignores the redis health", backend: "memory"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			viper.Set("code.cache", tc.backend)
+			repo := InitCodeRepository(InitCodeStore(redisClient, redisDown{}))
+
+			err := repo.Set(ctx, "bizLogin", "13800138000", "123456", service.DefaultCodePolicy)
+			assert.Equal(t, tc.wantErr, err)
+			if tc.wantErr != nil {
+				return
+			}
+			ok, err := repo.Verify(ctx, "bizLogin", "13800138000", "123456")
+			require.NoError(t, err)
+			assert.True(t, ok)
+		})
+	}
+}
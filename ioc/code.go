@@ -0,0 +1,57 @@
+package ioc
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cyvqet/connectify/internal/domain"
+	"github.com/cyvqet/connectify/internal/service"
+
+	"github.com/spf13/viper"
+)
+
+// InitCodePolicies loads per-bizType verification code policies.
+// Fields left out in the config fall back to service.DefaultCodePolicy.
+func InitCodePolicies() service.CodePolicies {
+	var cfgs []CodePolicyConfig
+	err := viper.UnmarshalKey("code.policies", &cfgs)
+	if err != nil {
+		panic(err)
+	}
+
+	policies := make(service.CodePolicies, len(cfgs))
+	for _, cfg := range cfgs {
+		policy := cfg.policy()
+		if err := validateCodePolicy(policy); err != nil {
+			panic(fmt.Errorf("code policy %q: %w", cfg.Biz, err))
+		}
+		policies[cfg.Biz] = policy
+	}
+	return policies
+}
+
+const (
+	minCodeLength = 4
+	maxCodeLength = 16
+)
+
+func validateCodePolicy(policy domain.CodePolicy) error {
+	if policy.Length < minCodeLength || policy.Length > maxCodeLength {
+		return fmt.Errorf("length %d must be within [%d, %d]", policy.Length, minCodeLength, maxCodeLength)
+	}
+	if utf8.RuneCountInString(policy.Alphabet) < 2 {
+		return fmt.Errorf("alphabet needs at least 2 characters")
+	}
+	// The lua scripts work in whole seconds
+	if policy.TTL < time.Second || policy.ResendInterval < time.Second {
+		return fmt.Errorf("ttl and resend interval must be at least 1s")
+	}
+	if policy.ResendInterval >= policy.TTL {
+		return fmt.Errorf("resend interval %v must be shorter than ttl %v", policy.ResendInterval, policy.TTL)
+	}
+	if policy.MaxAttempts < 1 {
+		return fmt.Errorf("max attempts must be at least 1")
+	}
+	return nil
+}
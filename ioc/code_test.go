@@ -0,0 +1,93 @@
+package ioc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cyvqet/connectify/internal/domain"
+	"github.com/cyvqet/connectify/internal/service"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCodePolicy(t *testing.T) {
+	policy := func(fn func(p *domain.CodePolicy)) domain.CodePolicy {
+		p := service.DefaultCodePolicy
+		fn(&p)
+		return p
+	}
+
+	testCases := []struct {
+		name    string
+		policy  domain.CodePolicy
+		wantErr string
+	}{
+		{
+			name:   "default",
+			policy: service.DefaultCodePolicy,
+		},
+		{
+			name:   "letters",
+			policy: policy(func(p *domain.CodePolicy) { p.Length = 8; p.Alphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" }),
+		},
+		{
+			name:    "too short",
+			policy:  policy(func(p *domain.CodePolicy) { p.Length = 3 }),
+			wantErr: "length 3 must be within [4, 16]",
+		},
+		{
+			name:    "too long",
+			policy:  policy(func(p *domain.CodePolicy) { p.Length = 17 }),
+			wantErr: "length 17 must be within [4, 16]",
+		},
+		{
+			name:    "single character alphabet",
+			policy:  policy(func(p *domain.CodePolicy) { p.Alphabet = "0" }),
+			wantErr: "alphabet needs at least 2 characters",
+		},
+		{
+			// Counted in characters, not bytes
+			name:   "multibyte alphabet",
+			policy: policy(func(p *domain.CodePolicy) { p.Alphabet = "甲乙" }),
+		},
+		{
+			name:    "ttl under a second",
+			policy:  policy(func(p *domain.CodePolicy) { p.TTL = 500 * time.Millisecond }),
+			wantErr: "ttl and resend interval must be at least 1s",
+		},
+		{
+			name:    "negative resend interval",
+			policy:  policy(func(p *domain.CodePolicy) { p.ResendInterval = -time.Second }),
+			wantErr: "ttl and resend interval must be at least 1s",
+		},
+		{
+			name:    "resend interval as long as the ttl",
+			policy:  policy(func(p *domain.CodePolicy) { p.ResendInterval = p.TTL }),
+			wantErr: "resend interval 10m0s must be shorter than ttl 10m0s",
+		},
+		{
+			name:    "no attempts",
+			policy:  policy(func(p *domain.CodePolicy) { p.MaxAttempts = -1 }),
+			wantErr: "max attempts must be at least 1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateCodePolicy(tc.policy)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestCodePolicyConfig_Policy(t *testing.T) {
+	// Left out fields fall back, negative ones are kept for validation to reject
+	got := CodePolicyConfig{Biz: "bizLogin", Length: 8, MaxAttempts: -1}.policy()
+	want := service.DefaultCodePolicy
+	want.Length = 8
+	want.MaxAttempts = -1
+	assert.Equal(t, want, got)
+}
@@ -0,0 +1,211 @@
+package ioc
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"go.yaml.in/yaml/v3"
+)
+
+// envPrefix marks the environment variables overriding the config file,
+// CONNECTIFY_REDIS_ADDR overrides redis.addr
+const envPrefix = "CONNECTIFY_"
+
+var (
+	configMu        sync.Mutex
+	configListeners []func()
+)
+
+// LoadConfig reads config/<env>.yaml, applies the environment variables and
+// secret files on top and validates the result, reporting every error at once
+func LoadConfig(env string) (Config, error) {
+	viper.SetConfigName(env)      // config file name (dev or k8s)
+	viper.SetConfigType("yaml")   // config file type
+	viper.AddConfigPath("config") // config file path
+	if err := viper.ReadInConfig(); err != nil {
+		return Config{}, err
+	}
+	if err := applyOverrides(); err != nil {
+		return Config{}, err
+	}
+
+	cfg := defaultConfig()
+	if err := viper.Unmarshal(&cfg); err != nil {
+		return Config{}, err
+	}
+	return cfg, cfg.Validate()
+}
+
+// applyOverrides merges CONNECTIFY_* variables and secret files into the
+// config read from the file. Secrets come from the file named by
+// CONNECTIFY_<KEY>_FILE or by <key>File in the config, e.g. db.mysql.dsnFile.
+//
+// They are merged rather than set with viper.Set, which would hide the
+// sibling keys from viper.UnmarshalKey of the enclosing section.
+func applyOverrides() error {
+	overrides := make(map[string]any)
+	secrets := make(map[string]bool)
+	for _, key := range secretKeys() {
+		secrets[strings.ToLower(key)] = true
+	}
+
+	for _, kv := range os.Environ() {
+		name, val, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, envPrefix) {
+			continue
+		}
+		key := strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(name, envPrefix), "_", "."))
+		if base, ok := strings.CutSuffix(key, ".file"); ok && secrets[base] {
+			// A secret file, read below
+			continue
+		}
+		setPath(overrides, key, val)
+	}
+
+	for key := range secrets {
+		path := os.Getenv(envPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_")) + "_FILE")
+		if path == "" {
+			path = viper.GetString(key + "File")
+		}
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read secret %s: %w", key, err)
+		}
+		setPath(overrides, key, strings.TrimRight(string(data), "\r\n"))
+	}
+
+	if len(overrides) == 0 {
+		return nil
+	}
+	return viper.MergeConfigMap(overrides)
+}
+
+// setPath sets the dotted key in the nested map m
+func setPath(m map[string]any, key string, val any) {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := m[part].(map[string]any)
+		if !ok {
+			next = make(map[string]any)
+			m[part] = next
+		}
+		m = next
+	}
+	m[parts[len(parts)-1]] = val
+}
+
+// secretKeys are the config keys of the fields tagged secret:"true"
+func secretKeys() []string {
+	var keys []string
+	var walk func(t reflect.Type, prefix string)
+	walk = func(t reflect.Type, prefix string) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			key := prefix + yamlName(f)
+			switch {
+			case f.Tag.Get("secret") == "true":
+				keys = append(keys, key)
+			case f.Type.Kind() == reflect.Struct && f.Type != reflect.TypeOf(time.Duration(0)):
+				walk(f.Type, key+".")
+			}
+		}
+	}
+	walk(reflect.TypeOf(Config{}), "")
+	return keys
+}
+
+func yamlName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// PrintConfig writes cfg as YAML with durations readable and secrets redacted
+func PrintConfig(w io.Writer, cfg Config) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(printable(reflect.ValueOf(cfg), false)); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// printable converts v to values yaml prints the way the config file is written
+func printable(v reflect.Value, secret bool) any {
+	if secret {
+		if v.IsZero() || (v.Kind() == reflect.Slice && v.Len() == 0) {
+			return ""
+		}
+		return "<redacted>"
+	}
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			var val yaml.Node
+			if err := val.Encode(printable(v.Field(i), f.Tag.Get("secret") == "true")); err != nil {
+				panic(err)
+			}
+			node.Content = append(node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: yamlName(f)}, &val)
+		}
+		return node
+	case reflect.Map:
+		// yaml sorts the keys
+		res := make(map[string]any, v.Len())
+		for _, k := range v.MapKeys() {
+			res[k.String()] = printable(v.MapIndex(k), false)
+		}
+		return res
+	case reflect.Slice:
+		res := make([]any, v.Len())
+		for i := range res {
+			res[i] = printable(v.Index(i), false)
+		}
+		return res
+	default:
+		return v.Interface()
+	}
+}
+
+// onConfigChange runs fn every time the config file changes.
+// viper keeps a single callback, so every component registers here instead.
+func onConfigChange(fn func()) {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	if len(configListeners) == 0 {
+		viper.OnConfigChange(func(e fsnotify.Event) {
+			// The file was read again without them. On failure the change is
+			// not announced, so components keep what they run with.
+			if err := applyOverrides(); err != nil {
+				return
+			}
+			configMu.Lock()
+			listeners := configListeners
+			configMu.Unlock()
+			for _, listener := range listeners {
+				listener()
+			}
+		})
+		viper.WatchConfig()
+	}
+	configListeners = append(configListeners, fn)
+}
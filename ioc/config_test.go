@@ -0,0 +1,120 @@
+package ioc
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useDevConfig runs the test in a directory holding a copy of config/dev.yaml
+func useDevConfig(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "config", "dev.yaml"))
+	require.NoError(t, err)
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.Mkdir("config", 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join("config", "dev.yaml"), data, 0o644))
+	t.Cleanup(viper.Reset)
+}
+
+func TestLoadConfig(t *testing.T) {
+	testCases := []struct {
+		name   string
+		before func(t *testing.T)
+
+		check   func(t *testing.T, cfg Config)
+		wantErr []string
+	}{
+		{
+			name: "file only",
+			check: func(t *testing.T, cfg Config) {
+				assert.Equal(t, "root:root@tcp(localhost:13316)/connectify", cfg.DB.MySQL.DSN)
+			},
+		},
+		{
+			name: "environment overrides the file",
+			before: func(t *testing.T) {
+				t.Setenv("CONNECTIFY_DB_MYSQL_DSN", "app:secret@tcp(mysql:3306)/connectify")
+			},
+			check: func(t *testing.T, cfg Config) {
+				assert.Equal(t, "app:secret@tcp(mysql:3306)/connectify", cfg.DB.MySQL.DSN)
+				// The rest of the section still comes from the file
+				assert.Equal(t, 20, cfg.DB.MySQL.MaxOpenConns)
+			},
+		},
+		{
+			name: "secret file",
+			before: func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), "jwt-key")
+				require.NoError(t, os.WriteFile(path, []byte("jwt-key-from-a-file\n"), 0o600))
+				t.Setenv("CONNECTIFY_JWT_KEY_FILE", path)
+			},
+			check: func(t *testing.T, cfg Config) {
+				assert.Equal(t, "jwt-key-from-a-file", cfg.JWT.Key)
+			},
+		},
+		{
+			name: "missing secret file",
+			before: func(t *testing.T) {
+				t.Setenv("CONNECTIFY_JWT_KEY_FILE", filepath.Join(t.TempDir(), "missing"))
+			},
+			wantErr: []string{"read secret jwt.key"},
+		},
+		{
+			name: "every validation error at once",
+			before: func(t *testing.T) {
+				t.Setenv("CONNECTIFY_JWT_KEY", "short")
+				t.Setenv("CONNECTIFY_REDIS_ADDR", "")
+				t.Setenv("CONNECTIFY_LOG_ENCODING", "xml")
+			},
+			wantErr: []string{
+				"jwt.key: needs at least 16 bytes",
+				"redis.addr: required",
+				`log.encoding: unknown encoding "xml"`,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			useDevConfig(t)
+			if tc.before != nil {
+				tc.before(t)
+			}
+
+			cfg, err := LoadConfig("dev")
+			if len(tc.wantErr) > 0 {
+				require.Error(t, err)
+				for _, want := range tc.wantErr {
+					assert.Contains(t, err.Error(), want)
+				}
+				return
+			}
+			require.NoError(t, err)
+			tc.check(t, cfg)
+		})
+	}
+}
+
+func TestPrintConfig(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.DB.MySQL.DSN = "app:db-password@tcp(mysql:3306)/connectify"
+	cfg.DB.MySQL.Replicas = []string{"app:replica-password@tcp(replica:3306)/connectify"}
+	cfg.JWT.Key = "jwt-signing-key-0123456789"
+	cfg.SMS.Internal.Key = "internal-sms-key-0123456789"
+
+	var buf bytes.Buffer
+	require.NoError(t, PrintConfig(&buf, cfg))
+	out := buf.String()
+	for _, secret := range []string{"db-password", "replica-password", "jwt-signing-key", "internal-sms-key"} {
+		assert.NotContains(t, out, secret)
+	}
+	assert.Contains(t, out, "dsn: <redacted>")
+	assert.Contains(t, out, "replicas: <redacted>")
+	// Secrets left empty show as such, and the rest is printed as written
+	assert.Contains(t, out, `secret: ""`)
+	assert.Contains(t, out, "shutdownTimeout: 20s")
+}
@@ -0,0 +1,400 @@
+package ioc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cyvqet/connectify/internal/domain"
+	"github.com/cyvqet/connectify/internal/service"
+	limiter "github.com/cyvqet/connectify/pkg/ratelimit"
+
+	"go.uber.org/zap"
+)
+
+// Config is the whole configuration, checked by LoadConfig before anything
+// is built. Components still read their own section from viper, so changes
+// to the file reach the ones that reload.
+type Config struct {
+	Server    ServerConfig    `yaml:"server"`
+	DB        DBConfig        `yaml:"db"`
+	Redis     RedisConfig     `yaml:"redis"`
+	JWT       JWTConfig       `yaml:"jwt"`
+	Admin     AdminConfig     `yaml:"admin"`
+	SMS       SMSConfig       `yaml:"sms"`
+	Voice     VoiceConfig     `yaml:"voice"`
+	Code      CodeConfig      `yaml:"code"`
+	RateLimit rateLimitConfig `yaml:"ratelimit"`
+	Cache     CacheConfig     `yaml:"cache"`
+	Degrade   DegradeConfig   `yaml:"degrade"`
+	Trace     TraceConfig     `yaml:"trace"`
+	AccessLog AccessLogConfig `yaml:"accesslog"`
+	Log       LogConfig       `yaml:"log"`
+	Health    HealthConfig    `yaml:"health"`
+}
+
+type ServerConfig struct {
+	Addr              string        `yaml:"addr"`
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout"`
+	ReadTimeout       time.Duration `yaml:"readTimeout"`
+	WriteTimeout      time.Duration `yaml:"writeTimeout"`
+	IdleTimeout       time.Duration `yaml:"idleTimeout"`
+	MaxHeaderBytes    int           `yaml:"maxHeaderBytes"`
+	ShutdownTimeout   time.Duration `yaml:"shutdownTimeout"`
+}
+
+type DBConfig struct {
+	MySQL   MySQLConfig   `yaml:"mysql"`
+	Migrate MigrateConfig `yaml:"migrate"`
+}
+
+type MigrateConfig struct {
+	// Auto applies the pending migrations at startup, otherwise the server
+	// refuses to start until `connectify migrate up` has run
+	Auto bool `yaml:"auto"`
+	// LockTimeout is how long to wait for another instance migrating
+	LockTimeout time.Duration `yaml:"lockTimeout"`
+}
+
+type MySQLConfig struct {
+	DSN string `yaml:"dsn" secret:"true"`
+	// Replicas serve the reads, the primary at DSN the writes and the reads
+	// of FindOrCreate. Without replicas the primary serves everything.
+	Replicas []string `yaml:"replicas" secret:"true"`
+
+	// Pool settings, applied to the primary and each replica. 0 is unlimited.
+	MaxOpenConns    int           `yaml:"maxOpenConns"`
+	MaxIdleConns    int           `yaml:"maxIdleConns"`
+	ConnMaxLifetime time.Duration `yaml:"connMaxLifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"connMaxIdleTime"`
+
+	// SlowThreshold logs slower statements as warnings, 0 disables it
+	SlowThreshold time.Duration `yaml:"slowThreshold"`
+}
+
+type RedisConfig struct {
+	Addr string `yaml:"addr"`
+}
+
+type JWTConfig struct {
+	// Key signs the login tokens
+	Key string `yaml:"key" secret:"true"`
+}
+
+type AdminConfig struct {
+	// UserIds may use the /admin routes, everyone else gets 403
+	UserIds []int64 `yaml:"userIds"`
+}
+
+type SMSConfig struct {
+	RateLimit limiterConfig      `yaml:"ratelimit"`
+	Quota     SMSQuotaConfig     `yaml:"quota"`
+	Internal  SMSInternalConfig  `yaml:"internal"`
+	Receipt   SMSReceiptConfig   `yaml:"receipt"`
+	Providers SMSProvidersConfig `yaml:"providers"`
+}
+
+type SMSQuotaConfig struct {
+	PhoneHourly int `yaml:"phoneHourly"`
+	PhoneDaily  int `yaml:"phoneDaily"`
+	IPDaily     int `yaml:"ipDaily"`
+	BizDaily    int `yaml:"bizDaily"`
+}
+
+type SMSInternalConfig struct {
+	// Key signs the service tokens of internal callers
+	Key        string                  `yaml:"key" secret:"true"`
+	Businesses map[string]SMSBizConfig `yaml:"businesses"`
+}
+
+type SMSReceiptConfig struct {
+	// Secret signs the delivery receipts pushed by the providers
+	Secret string `yaml:"secret" secret:"true"`
+}
+
+type SMSBizConfig struct {
+	Templates []string      `yaml:"templates"`
+	Interval  time.Duration `yaml:"interval"`
+	Rate      int           `yaml:"rate"`
+}
+
+type SMSProvidersConfig struct {
+	Tencent SMSProviderConfig `yaml:"tencent"`
+	Aliyun  SMSProviderConfig `yaml:"aliyun"`
+}
+
+type SMSProviderConfig struct {
+	AppId    string `yaml:"appId"`
+	SignName string `yaml:"signName"`
+}
+
+type VoiceConfig struct {
+	// tencent, or local to only log the calls
+	Provider  string               `yaml:"provider"`
+	Providers VoiceProvidersConfig `yaml:"providers"`
+}
+
+type VoiceProvidersConfig struct {
+	Tencent VoiceProviderConfig `yaml:"tencent"`
+}
+
+type VoiceProviderConfig struct {
+	AppId string `yaml:"appId"`
+}
+
+type CodeConfig struct {
+	// redis, or memory for a single local instance that keeps codes while
+	// Redis is down. The send quotas are in Redis either way.
+	Cache    string             `yaml:"cache"`
+	Policies []CodePolicyConfig `yaml:"policies"`
+}
+
+// CodePolicyConfig fields left out fall back to service.DefaultCodePolicy
+type CodePolicyConfig struct {
+	Biz            string        `yaml:"biz"`
+	Length         int           `yaml:"length"`
+	Alphabet       string        `yaml:"alphabet"`
+	TTL            time.Duration `yaml:"ttl"`
+	ResendInterval time.Duration `yaml:"resendInterval"`
+	MaxAttempts    int           `yaml:"maxAttempts"`
+}
+
+type CacheConfig struct {
+	User UserCacheConfig `yaml:"user"`
+}
+
+type UserCacheConfig struct {
+	Expire         time.Duration `yaml:"expire"`
+	Jitter         time.Duration `yaml:"jitter"`
+	NotFoundExpire time.Duration `yaml:"notFoundExpire"`
+}
+
+type DegradeConfig struct {
+	Redis               RedisHealthConfig `yaml:"redis"`
+	UserReadConcurrency int64             `yaml:"userReadConcurrency"`
+}
+
+type RedisHealthConfig struct {
+	Interval  time.Duration `yaml:"interval"`
+	Timeout   time.Duration `yaml:"timeout"`
+	Threshold int           `yaml:"threshold"`
+}
+
+type TraceConfig struct {
+	ServiceName string     `yaml:"serviceName"`
+	Exporter    string     `yaml:"exporter"`
+	SampleRatio float64    `yaml:"sampleRatio"`
+	OTLP        OTLPConfig `yaml:"otlp"`
+}
+
+type OTLPConfig struct {
+	Endpoint string `yaml:"endpoint"`
+	Insecure bool   `yaml:"insecure"`
+}
+
+type AccessLogConfig struct {
+	ReqBody     bool     `yaml:"reqBody"`
+	RespBody    bool     `yaml:"respBody"`
+	MaxBodySize int      `yaml:"maxBodySize"`
+	MaskFields  []string `yaml:"maskFields"`
+	// Routes whose bodies are never logged, masked or not
+	SkipBodyRoutes []string `yaml:"skipBodyRoutes"`
+}
+
+type LogConfig struct {
+	Level    string            `yaml:"level"`
+	Encoding string            `yaml:"encoding"`
+	Stdout   bool              `yaml:"stdout"`
+	File     LogFileConfig     `yaml:"file"`
+	Sampling LogSamplingConfig `yaml:"sampling"`
+}
+
+type LogFileConfig struct {
+	// Empty disables file output
+	Path        string        `yaml:"path"`
+	MaxSize     int           `yaml:"maxSize"`
+	MaxAge      int           `yaml:"maxAge"`
+	MaxBackups  int           `yaml:"maxBackups"`
+	Compress    bool          `yaml:"compress"`
+	RotateEvery time.Duration `yaml:"rotateEvery"`
+}
+
+type LogSamplingConfig struct {
+	Initial    int `yaml:"initial"`
+	Thereafter int `yaml:"thereafter"`
+}
+
+type HealthConfig struct {
+	Timeout time.Duration `yaml:"timeout"`
+	// Provider name → host:port checked for reachability
+	SMS map[string]string `yaml:"sms"`
+}
+
+// credentialRoutes carry passwords, codes and tokens in their bodies,
+// the access log leaves them out unless accesslog.skipBodyRoutes says otherwise
+var credentialRoutes = []string{
+	"/user/signup", "/user/login", "/user/login_jwt", "/user/send_sms_code", "/user/login_sms",
+}
+
+// defaultConfig holds the values used for everything the config file leaves out
+func defaultConfig() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:              ":8080",
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       10 * time.Second,
+			WriteTimeout:      10 * time.Second,
+			IdleTimeout:       time.Minute,
+			MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+			ShutdownTimeout:   20 * time.Second,
+		},
+		DB: DBConfig{
+			MySQL: MySQLConfig{
+				MaxOpenConns:    100,
+				MaxIdleConns:    10,
+				ConnMaxLifetime: 30 * time.Minute,
+				ConnMaxIdleTime: 5 * time.Minute,
+				SlowThreshold:   200 * time.Millisecond,
+			},
+			Migrate: MigrateConfig{LockTimeout: time.Minute},
+		},
+		Code:  CodeConfig{Cache: "redis"},
+		Cache: CacheConfig{User: UserCacheConfig{Expire: 10 * time.Minute}},
+		Degrade: DegradeConfig{
+			Redis: RedisHealthConfig{
+				Interval:  time.Second,
+				Timeout:   500 * time.Millisecond,
+				Threshold: 3,
+			},
+			UserReadConcurrency: 50,
+		},
+		Trace:     TraceConfig{ServiceName: "connectify", Exporter: "none", SampleRatio: 1},
+		AccessLog: AccessLogConfig{MaxBodySize: 1024, SkipBodyRoutes: credentialRoutes},
+		Log:       LogConfig{Level: "info", Encoding: "console", Stdout: true},
+		Health:    HealthConfig{Timeout: time.Second},
+	}
+}
+
+// Validate reports every problem at once, each prefixed with its config key
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, key, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: "+format, append([]any{key}, args...)...))
+		}
+	}
+
+	check(c.Server.Addr != "", "server.addr", "required")
+	check(c.Server.ReadHeaderTimeout >= 0 && c.Server.ReadTimeout >= 0 &&
+		c.Server.WriteTimeout >= 0 && c.Server.IdleTimeout >= 0, "server", "timeouts must not be negative")
+	check(c.Server.MaxHeaderBytes > 0, "server.maxHeaderBytes", "must be positive")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdownTimeout", "must be positive")
+
+	mc := c.DB.MySQL
+	check(mc.DSN != "", "db.mysql.dsn", "required")
+	for i, dsn := range mc.Replicas {
+		check(dsn != "", fmt.Sprintf("db.mysql.replicas[%d]", i), "required")
+	}
+	check(mc.MaxOpenConns >= 0 && mc.MaxIdleConns >= 0, "db.mysql", "pool sizes must not be negative")
+	check(mc.MaxOpenConns == 0 || mc.MaxIdleConns <= mc.MaxOpenConns, "db.mysql.maxIdleConns",
+		"must not exceed maxOpenConns")
+	check(mc.ConnMaxLifetime >= 0 && mc.ConnMaxIdleTime >= 0 && mc.SlowThreshold >= 0, "db.mysql",
+		"durations must not be negative")
+	check(c.DB.Migrate.LockTimeout >= time.Second, "db.migrate.lockTimeout", "must be at least 1s")
+	check(c.Redis.Addr != "", "redis.addr", "required")
+	check(len(c.JWT.Key) >= 16, "jwt.key", "needs at least 16 bytes")
+
+	check(c.SMS.RateLimit.Interval > 0 && c.SMS.RateLimit.Rate > 0,
+		"sms.ratelimit", "interval and rate must be positive")
+	check(c.SMS.Quota.PhoneHourly >= 0 && c.SMS.Quota.PhoneDaily >= 0 &&
+		c.SMS.Quota.IPDaily >= 0 && c.SMS.Quota.BizDaily >= 0, "sms.quota", "must not be negative")
+	check(len(c.SMS.Internal.Businesses) == 0 || len(c.SMS.Internal.Key) >= 16,
+		"sms.internal.key", "needs at least 16 bytes")
+	for biz, bc := range c.SMS.Internal.Businesses {
+		key := "sms.internal.businesses." + biz
+		check(len(bc.Templates) > 0, key+".templates", "required")
+		check(bc.Interval > 0 && bc.Rate > 0, key, "interval and rate must be positive")
+	}
+
+	check(len(c.SMS.Receipt.Secret) >= 16, "sms.receipt.secret", "needs at least 16 bytes")
+
+	switch c.Voice.Provider {
+	case "tencent":
+		check(c.Voice.Providers.Tencent.AppId != "", "voice.providers.tencent.appId", "required by the tencent provider")
+	case "local":
+	default:
+		check(false, "voice.provider", "unknown provider %q", c.Voice.Provider)
+	}
+
+	check(c.Code.Cache == "redis" || c.Code.Cache == "memory", "code.cache", "unknown backend %q", c.Code.Cache)
+	bizs := make(map[string]bool, len(c.Code.Policies))
+	for i, pc := range c.Code.Policies {
+		key := fmt.Sprintf("code.policies[%d]", i)
+		check(pc.Biz != "", key+".biz", "required")
+		check(!bizs[pc.Biz], key+".biz", "duplicate %q", pc.Biz)
+		bizs[pc.Biz] = true
+		if err := validateCodePolicy(pc.policy()); err != nil {
+			check(false, key, "%v", err)
+		}
+	}
+
+	_, err := limiter.ParseFallbackMode(c.RateLimit.Fallback)
+	check(err == nil, "ratelimit.fallback", "%v", err)
+	check(len(c.RateLimit.Rules) > 0, "ratelimit.rules", "required")
+	for i, rc := range c.RateLimit.Rules {
+		key := fmt.Sprintf("ratelimit.rules[%d]", i)
+		check(rc.Name != "", key+".name", "required")
+		check(rc.Interval > 0 && rc.Rate > 0, key, "interval and rate must be positive")
+	}
+
+	check(c.Cache.User.Expire > 0, "cache.user.expire", "must be positive")
+	check(c.Cache.User.Jitter >= 0 && c.Cache.User.NotFoundExpire >= 0, "cache.user", "must not be negative")
+
+	check(c.Degrade.Redis.Interval > 0 && c.Degrade.Redis.Timeout > 0, "degrade.redis",
+		"interval and timeout must be positive")
+	check(c.Degrade.Redis.Threshold > 0, "degrade.redis.threshold", "must be positive")
+	check(c.Degrade.UserReadConcurrency > 0, "degrade.userReadConcurrency", "must be positive")
+
+	switch c.Trace.Exporter {
+	case "otlp":
+		check(c.Trace.OTLP.Endpoint != "", "trace.otlp.endpoint", "required by the otlp exporter")
+	case "stdout", "none":
+	default:
+		check(false, "trace.exporter", "unknown exporter %q", c.Trace.Exporter)
+	}
+	check(c.Trace.SampleRatio >= 0 && c.Trace.SampleRatio <= 1, "trace.sampleRatio", "must be within [0, 1]")
+
+	check(c.AccessLog.MaxBodySize >= 0, "accesslog.maxBodySize", "must not be negative")
+
+	_, err = zap.ParseAtomicLevel(c.Log.Level)
+	check(err == nil, "log.level", "%v", err)
+	check(c.Log.Encoding == "json" || c.Log.Encoding == "console", "log.encoding", "unknown encoding %q", c.Log.Encoding)
+	check(c.Log.Stdout || c.Log.File.Path != "", "log", "no output, enable stdout or set file.path")
+
+	check(c.Health.Timeout > 0, "health.timeout", "must be positive")
+
+	return errors.Join(errs...)
+}
+
+// policy is the configured policy on top of service.DefaultCodePolicy.
+// Only the fields left out fall back, negative ones are left to validation.
+func (pc CodePolicyConfig) policy() domain.CodePolicy {
+	policy := service.DefaultCodePolicy
+	if pc.Length != 0 {
+		policy.Length = pc.Length
+	}
+	if pc.Alphabet != "" {
+		policy.Alphabet = pc.Alphabet
+	}
+	if pc.TTL != 0 {
+		policy.TTL = pc.TTL
+	}
+	if pc.ResendInterval != 0 {
+		policy.ResendInterval = pc.ResendInterval
+	}
+	if pc.MaxAttempts != 0 {
+		policy.MaxAttempts = pc.MaxAttempts
+	}
+	return policy
+}
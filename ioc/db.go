@@ -1,30 +1,104 @@
 package ioc
 
 import (
-	"github.com/cyvqet/connectify/internal/repository/dao"
+	"context"
+	"database/sql"
+
+	"github.com/cyvqet/connectify/pkg/gormx"
+	"github.com/cyvqet/connectify/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/trace"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 )
 
-func InitDB() *gorm.DB {
-	type DBConfig struct {
-		DSN string `yaml:"dsn"`
-	}
-	var dbConfig DBConfig
+func InitDB(tp trace.TracerProvider, reg prometheus.Registerer, l logger.Logger) *gorm.DB {
+	dbConfig := defaultConfig().DB.MySQL
 	err := viper.UnmarshalKey("db.mysql", &dbConfig)
 	if err != nil {
 		panic(err)
 	}
 
-	db, err := gorm.Open(mysql.Open(dbConfig.DSN))
+	db, err := gorm.Open(mysql.Open(dbConfig.DSN), &gorm.Config{
+		Logger: gormx.NewLogger(l, dbConfig.SlowThreshold),
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	// Every pool, the primary's and one per replica, is sized alike
+	pools := func(fn func(sqlDB *sql.DB) error) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return fn(sqlDB)
+	}
+	if len(dbConfig.Replicas) > 0 {
+		replicas := make([]gorm.Dialector, 0, len(dbConfig.Replicas))
+		for _, dsn := range dbConfig.Replicas {
+			replicas = append(replicas, mysql.Open(dsn))
+		}
+		// Queries go to a random replica, everything else and the queries
+		// of dao.WithPrimary to the primary
+		resolver := dbresolver.Register(dbresolver.Config{
+			Replicas: replicas,
+			Policy:   dbresolver.RandomPolicy{},
+		})
+		err = db.Use(resolver)
+		if err != nil {
+			panic(err)
+		}
+		pools = func(fn func(sqlDB *sql.DB) error) error {
+			return resolver.Call(func(pool gorm.ConnPool) error {
+				if sqlDB, ok := pool.(*sql.DB); ok {
+					return fn(sqlDB)
+				}
+				return nil
+			})
+		}
+	}
+	err = pools(func(sqlDB *sql.DB) error {
+		sqlDB.SetMaxOpenConns(dbConfig.MaxOpenConns)
+		sqlDB.SetMaxIdleConns(dbConfig.MaxIdleConns)
+		sqlDB.SetConnMaxLifetime(dbConfig.ConnMaxLifetime)
+		sqlDB.SetConnMaxIdleTime(dbConfig.ConnMaxIdleTime)
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = db.Use(gormx.NewPrometheusPlugin(reg, "connectify", "db"))
 	if err != nil {
 		panic(err)
 	}
-	err = dao.InitTables(db)
+	err = db.Use(gormx.NewTracingPlugin(tp))
+	if err != nil {
+		panic(err)
+	}
+
+	registerReadinessCheck("mysql", true, func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+
+	onShutdown(phaseClients, "mysql", func(context.Context) error {
+		return pools(func(sqlDB *sql.DB) error {
+			return sqlDB.Close()
+		})
+	})
+
+	sqlDB, err := db.DB()
 	if err != nil {
 		panic(err)
 	}
+	checkSchema(sqlDB, l)
 	return db
 }
@@ -0,0 +1,68 @@
+package ioc
+
+import (
+	"context"
+
+	"github.com/cyvqet/connectify/internal/errs"
+	"github.com/cyvqet/connectify/internal/i18n"
+	"github.com/cyvqet/connectify/internal/repository"
+	"github.com/cyvqet/connectify/internal/repository/cache"
+	"github.com/cyvqet/connectify/internal/repository/dao"
+	"github.com/cyvqet/connectify/internal/web"
+	"github.com/cyvqet/connectify/pkg/ginx"
+	"github.com/cyvqet/connectify/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+// InitRedisHealthChecker starts pinging Redis, its state switches degraded mode on and off
+func InitRedisHealthChecker(redisClient redis.Cmdable, l logger.Logger) *cache.RedisHealthChecker {
+	cfg := defaultConfig().Degrade.Redis
+	err := viper.UnmarshalKey("degrade.redis", &cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	h := cache.NewRedisHealthChecker(redisClient, cfg.Interval, cfg.Timeout, cfg.Threshold, l)
+	h.Start(context.Background())
+	onShutdown(phaseWorkers, "redis health checker", func(context.Context) error {
+		h.Stop()
+		return nil
+	})
+	return h
+}
+
+func InitUserRepository(d dao.UserDao, c cache.UserCache, health cache.Health,
+	l logger.Logger) repository.UserRepository {
+	// Database reads by id allowed at once while Redis is down
+	concurrency := viper.GetInt64("degrade.userReadConcurrency")
+	if concurrency <= 0 {
+		concurrency = defaultConfig().Degrade.UserReadConcurrency
+	}
+	return repository.NewUserRepository(d, c, health, concurrency, l)
+}
+
+// degradeStatusHandler shows whether Redis is up and which features are degraded.
+// codeHealth is the Health of the code store, see InitCodeStore.
+func degradeStatusHandler(h *cache.RedisHealthChecker, codeHealth cache.Health) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		status := h.Status()
+		ginx.Render(ctx, ginx.Result{Msg: i18n.Msg(web.Lang(ctx), errs.OK), Data: gin.H{
+			"redis": gin.H{
+				"healthy":   status.Healthy,
+				"since":     status.Since,
+				"lastError": status.LastError,
+			},
+			"degraded": gin.H{
+				// Reads go to MySQL with limited concurrency, no cache writes
+				"userCache": !status.Healthy,
+				// Codes already sent can be verified, e.g. with code.cache memory
+				"smsLogin": !codeHealth.Healthy(),
+				// New codes are counted against the quotas in Redis
+				"smsSend": !status.Healthy,
+			},
+		}})
+	}
+}
@@ -0,0 +1,65 @@
+package ioc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cyvqet/connectify/internal/repository/cache"
+	"github.com/cyvqet/connectify/pkg/logger"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDegradeStatusHandler_RedisDown(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	h := cache.NewRedisHealthChecker(redisClient, 10*time.Millisecond, 50*time.Millisecond, 1, logger.NewNopLogger())
+	mr.Close()
+	h.Start(context.Background())
+	t.Cleanup(h.Stop)
+	require.Eventually(t, func() bool { return !h.Healthy() }, time.Second, 10*time.Millisecond)
+
+	testCases := []struct {
+		backend string
+
+		wantSMSLogin bool
+	}{
+		{backend: "redis", wantSMSLogin: true},
+		// Codes already sent are still verified
+		{backend: "memory", wantSMSLogin: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.backend, func(t *testing.T) {
+			viper.Set("code.cache", tc.backend)
+			store := InitCodeStore(redisClient, h)
+
+			gin.SetMode(gin.TestMode)
+			server := gin.New()
+			server.GET("/admin/degrade/status", degradeStatusHandler(h, store.Health))
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin/degrade/status", nil))
+
+			var res struct {
+				Data struct {
+					Degraded map[string]bool `json:"degraded"`
+				} `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+			assert.Equal(t, map[string]bool{
+				"userCache": true,
+				"smsLogin":  tc.wantSMSLogin,
+				"smsSend":   true,
+			}, res.Data.Degraded)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package ioc
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/cyvqet/connectify/internal/errs"
+	"github.com/cyvqet/connectify/internal/i18n"
+	"github.com/cyvqet/connectify/internal/web"
+	"github.com/cyvqet/connectify/pkg/ginx"
+	"github.com/cyvqet/connectify/pkg/health"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+// readiness holds the checks behind /readyz, components register their own
+// dependencies while they are built
+var readiness = health.NewRegistry()
+
+// registerReadinessCheck adds a dependency to /readyz, within health.timeout.
+// Only critical failures take the instance out of rotation, the others
+// are reported as degraded.
+func registerReadinessCheck(name string, critical bool, fn health.CheckerFunc) {
+	timeout := viper.GetDuration("health.timeout")
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	readiness.Register(name, fn, timeout, critical)
+}
+
+// registerSMSReachability checks that the provider endpoint in
+// health.sms.<provider> (host:port) accepts connections, if one is set.
+// Not critical: every instance shares the network, taking them all out
+// of rotation would not bring the provider back.
+func registerSMSReachability(provider string) {
+	addr := viper.GetString("health.sms." + provider)
+	if addr == "" {
+		return
+	}
+	registerReadinessCheck("sms."+provider, false, func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
+}
+
+// healthHandlers registers the probes. /healthz only tells the process
+// serves requests, /readyz checks the dependencies.
+func healthHandlers(server *gin.Engine) {
+	server.GET("/healthz", func(ctx *gin.Context) {
+		ginx.Render(ctx, ginx.Result{Msg: i18n.Msg(web.Lang(ctx), errs.OK),
+			Data: gin.H{"status": health.StatusUp}})
+	})
+	server.GET("/readyz", func(ctx *gin.Context) {
+		report := readiness.Check(ctx.Request.Context())
+		res := ginx.Result{Msg: i18n.Msg(web.Lang(ctx), errs.OK), Data: report}
+		if report.Status == health.StatusDown {
+			res.Code = errs.NotReady
+			res.Msg = i18n.Msg(web.Lang(ctx), errs.NotReady)
+		}
+		ginx.Render(ctx, res)
+	})
+}
@@ -1,15 +1,147 @@
 package ioc
 
 import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/cyvqet/connectify/internal/errs"
+	"github.com/cyvqet/connectify/internal/i18n"
+	"github.com/cyvqet/connectify/internal/web"
+	"github.com/cyvqet/connectify/pkg/ginx"
 	"github.com/cyvqet/connectify/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-func InitLogger() logger.Logger {
-	cfg := zap.NewDevelopmentConfig()
-	l, err := cfg.Build()
+// InitLogLevel is the level of every logger, GET and PUT /admin/log/level
+// read and change it at runtime
+func InitLogLevel() zap.AtomicLevel {
+	level, err := zap.ParseAtomicLevel(viper.GetString("log.level"))
+	if err != nil {
+		panic(err)
+	}
+	return level
+}
+
+func InitLogger(level zap.AtomicLevel) logger.Logger {
+	cfg := defaultConfig().Log
+	err := viper.UnmarshalKey("log", &cfg)
 	if err != nil {
 		panic(err)
 	}
+
+	// One core per sink, only the terminal gets colored levels
+	var cores []zapcore.Core
+	if cfg.Stdout {
+		cores = append(cores, newLogCore(cfg, zapcore.Lock(os.Stdout), level, true))
+	}
+	if cfg.File.Path != "" {
+		file := newLogFile(cfg.File)
+		if cfg.File.RotateEvery > 0 {
+			ctx, cancel := context.WithCancel(context.Background())
+			go rotateEvery(ctx, file, cfg.File.RotateEvery)
+			onShutdown(phaseWorkers, "log rotation", func(context.Context) error {
+				cancel()
+				return nil
+			})
+		}
+		// Registered before the sync hook below, so it runs after it
+		onShutdown(phaseTelemetry, "log file", func(context.Context) error {
+			return file.Close()
+		})
+		cores = append(cores, newLogCore(cfg, zapcore.AddSync(file), level, false))
+	}
+	if len(cores) == 0 {
+		panic("log has no output, enable log.stdout or set log.file.path")
+	}
+
+	l := zap.New(zapcore.NewTee(cores...), zap.AddCaller(), zap.AddCallerSkip(1), zap.AddStacktrace(zapcore.ErrorLevel))
+	// For libraries logging through zap's globals
+	zap.ReplaceGlobals(l)
+	onShutdown(phaseTelemetry, "logger", func(context.Context) error {
+		// Syncing stdout fails on terminals and pipes, it is not buffered anyway
+		if err := l.Sync(); err != nil && cfg.File.Path != "" {
+			return err
+		}
+		return nil
+	})
 	return logger.NewZapLogger(l)
 }
+
+// newLogCore writes entries at level or above to sink, encoded and sampled as cfg says.
+// color only applies to the console encoding, escape codes do not belong in files.
+func newLogCore(cfg LogConfig, sink zapcore.WriteSyncer, level zapcore.LevelEnabler, color bool) zapcore.Core {
+	encCfg := zap.NewProductionEncoderConfig()
+	encCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+	var enc zapcore.Encoder
+	switch cfg.Encoding {
+	case "json":
+		enc = zapcore.NewJSONEncoder(encCfg)
+	case "console":
+		encCfg.EncodeLevel = zapcore.CapitalLevelEncoder
+		if color {
+			encCfg.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		}
+		enc = zapcore.NewConsoleEncoder(encCfg)
+	default:
+		panic("unknown log encoding: " + cfg.Encoding)
+	}
+
+	core := zapcore.NewCore(enc, sink, level)
+	if cfg.Sampling.Initial > 0 {
+		// Per second and message, the first Initial entries are kept, then every Thereafter-th
+		core = zapcore.NewSamplerWithOptions(core, time.Second, cfg.Sampling.Initial, cfg.Sampling.Thereafter)
+	}
+	return core
+}
+
+// newLogFile rotates by size, rotateEvery is up to the caller
+func newLogFile(cfg LogFileConfig) *lumberjack.Logger {
+	return &lumberjack.Logger{
+		Filename:   cfg.Path,
+		MaxSize:    cfg.MaxSize,
+		MaxAge:     cfg.MaxAge,
+		MaxBackups: cfg.MaxBackups,
+		Compress:   cfg.Compress,
+		LocalTime:  true,
+	}
+}
+
+// rotateEvery starts a new file every interval, on top of the size limit
+func rotateEvery(ctx context.Context, file *lumberjack.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = file.Rotate()
+		}
+	}
+}
+
+type LogLevelReq struct {
+	Level string `json:"level"`
+}
+
+// logLevelHandlers read and set the level of every logger, without restart
+func logLevelHandlers(server *gin.Engine, level zap.AtomicLevel, w *ginx.Wrapper) {
+	server.GET("/admin/log/level", func(ctx *gin.Context) {
+		ginx.Render(ctx, ginx.Result{Msg: i18n.Msg(web.Lang(ctx), errs.OK),
+			Data: gin.H{"level": level.String()}})
+	})
+	server.PUT("/admin/log/level", ginx.WrapBody(w, func(ctx *gin.Context, req LogLevelReq) (ginx.Result, error) {
+		l, err := zapcore.ParseLevel(req.Level)
+		if err != nil {
+			return web.Result(ctx, errs.InvalidInput), nil
+		}
+		level.SetLevel(l)
+		return ginx.Result{Msg: i18n.Msg(web.Lang(ctx), errs.OK), Data: gin.H{"level": l.String()}}, nil
+	}))
+}
@@ -0,0 +1,209 @@
+package ioc
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cyvqet/connectify/internal/web"
+	"github.com/cyvqet/connectify/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestInitLogLevel(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	testCases := []struct {
+		level     string
+		want      zapcore.Level
+		wantPanic bool
+	}{
+		{level: "debug", want: zapcore.DebugLevel},
+		{level: "WARN", want: zapcore.WarnLevel},
+		{level: "error", want: zapcore.ErrorLevel},
+		{level: "verbose", wantPanic: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.level, func(t *testing.T) {
+			viper.Set("log.level", tc.level)
+			if tc.wantPanic {
+				assert.Panics(t, func() { InitLogLevel() })
+				return
+			}
+			assert.Equal(t, tc.want, InitLogLevel().Level())
+		})
+	}
+}
+
+func TestNewLogCore(t *testing.T) {
+	testCases := []struct {
+		name  string
+		cfg   LogConfig
+		level zapcore.Level
+		color bool
+		// Each entry is logged 10 times in a row
+		entries []zapcore.Level
+
+		wantLines int
+		check     func(t *testing.T, line string)
+	}{
+		{
+			name:      "json",
+			cfg:       LogConfig{Encoding: "json"},
+			level:     zapcore.InfoLevel,
+			entries:   []zapcore.Level{zapcore.InfoLevel},
+			wantLines: 10,
+			check: func(t *testing.T, line string) {
+				var entry map[string]any
+				require.NoError(t, json.Unmarshal([]byte(line), &entry))
+				assert.Equal(t, "info", entry["level"])
+				assert.Equal(t, "hello", entry["msg"])
+			},
+		},
+		{
+			name:      "console",
+			cfg:       LogConfig{Encoding: "console"},
+			level:     zapcore.InfoLevel,
+			entries:   []zapcore.Level{zapcore.InfoLevel},
+			wantLines: 10,
+			check: func(t *testing.T, line string) {
+				assert.Contains(t, line, "\tINFO\t")
+				assert.NotContains(t, line, "\x1b[")
+				assert.Contains(t, line, "hello")
+			},
+		},
+		{
+			name:      "console colored",
+			cfg:       LogConfig{Encoding: "console"},
+			level:     zapcore.InfoLevel,
+			color:     true,
+			entries:   []zapcore.Level{zapcore.InfoLevel},
+			wantLines: 10,
+			check: func(t *testing.T, line string) {
+				assert.Contains(t, line, "\x1b[34mINFO\x1b[0m")
+			},
+		},
+		{
+			name:      "below the level",
+			cfg:       LogConfig{Encoding: "json"},
+			level:     zapcore.WarnLevel,
+			entries:   []zapcore.Level{zapcore.DebugLevel, zapcore.InfoLevel, zapcore.WarnLevel},
+			wantLines: 10,
+		},
+		{
+			// The first 2, then every 3rd: the 5th and the 8th
+			name:      "sampled",
+			cfg:       LogConfig{Encoding: "json", Sampling: LogSamplingConfig{Initial: 2, Thereafter: 3}},
+			level:     zapcore.InfoLevel,
+			entries:   []zapcore.Level{zapcore.InfoLevel},
+			wantLines: 4,
+		},
+		{
+			name:      "sampled per level",
+			cfg:       LogConfig{Encoding: "json", Sampling: LogSamplingConfig{Initial: 2, Thereafter: 3}},
+			level:     zapcore.InfoLevel,
+			entries:   []zapcore.Level{zapcore.InfoLevel, zapcore.ErrorLevel},
+			wantLines: 8,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l := zap.New(newLogCore(tc.cfg, zapcore.AddSync(&buf), tc.level, tc.color))
+			for _, level := range tc.entries {
+				for range 10 {
+					l.Log(level, "hello")
+				}
+			}
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			require.Len(t, lines, tc.wantLines)
+			if tc.check != nil {
+				tc.check(t, lines[0])
+			}
+		})
+	}
+
+	t.Run("unknown encoding", func(t *testing.T) {
+		assert.Panics(t, func() {
+			newLogCore(LogConfig{Encoding: "xml"}, zapcore.AddSync(&bytes.Buffer{}), zapcore.InfoLevel, false)
+		})
+	})
+}
+
+func TestNewLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "connectify.log")
+	file := newLogFile(LogFileConfig{Path: path, MaxSize: 100, MaxAge: 7, MaxBackups: 10, Compress: true})
+	t.Cleanup(func() { _ = file.Close() })
+
+	assert.Equal(t, path, file.Filename)
+	assert.Equal(t, 100, file.MaxSize)
+	assert.Equal(t, 7, file.MaxAge)
+	assert.Equal(t, 10, file.MaxBackups)
+	assert.True(t, file.Compress)
+	assert.True(t, file.LocalTime)
+
+	_, err := file.Write([]byte("hello\n"))
+	require.NoError(t, err)
+	require.NoError(t, file.Rotate())
+	matches, err := filepath.Glob(filepath.Join(filepath.Dir(path), "connectify-*.log*"))
+	require.NoError(t, err)
+	assert.Len(t, matches, 1)
+}
+
+func TestLogLevelHandlers(t *testing.T) {
+	testCases := []struct {
+		name   string
+		method string
+		body   string
+
+		wantBody  string
+		wantLevel zapcore.Level
+	}{
+		{
+			name:      "get",
+			method:    http.MethodGet,
+			wantBody:  `{"code":0,"msg":"ok","data":{"level":"info"}}`,
+			wantLevel: zapcore.InfoLevel,
+		},
+		{
+			name:      "set",
+			method:    http.MethodPut,
+			body:      `{"level":"debug"}`,
+			wantBody:  `{"code":0,"msg":"ok","data":{"level":"debug"}}`,
+			wantLevel: zapcore.DebugLevel,
+		},
+		{
+			name:      "unknown level",
+			method:    http.MethodPut,
+			body:      `{"level":"verbose"}`,
+			wantBody:  `{"code":400000,"msg":"invalid request"}`,
+			wantLevel: zapcore.InfoLevel,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+			gin.SetMode(gin.TestMode)
+			server := gin.New()
+			logLevelHandlers(server, level, web.NewWrapper(logger.NewNopLogger()))
+
+			req := httptest.NewRequest(tc.method, "/admin/log/level", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.JSONEq(t, tc.wantBody, recorder.Body.String())
+			assert.Equal(t, tc.wantLevel, level.Level())
+		})
+	}
+}
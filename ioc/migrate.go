@@ -0,0 +1,80 @@
+package ioc
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/cyvqet/connectify/internal/repository/dao"
+	"github.com/cyvqet/connectify/pkg/logger"
+	"github.com/cyvqet/connectify/pkg/migrate"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/spf13/viper"
+)
+
+// migratePingTimeout bounds the check that the database is reachable
+const migratePingTimeout = 5 * time.Second
+
+// InitMigrator backs the migrate subcommand, on a pool of its own to the
+// primary that lives as long as the command. The returned func closes it.
+func InitMigrator(l logger.Logger) (*migrate.Migrator, func(), error) {
+	cfg := defaultConfig().DB
+	err := viper.UnmarshalKey("db", &cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := sql.Open("mysql", cfg.MySQL.DSN)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Fail on a wrong DSN here rather than somewhere in GET_LOCK
+	ctx, cancel := context.WithTimeout(context.Background(), migratePingTimeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, nil, fmt.Errorf("connect to mysql: %w", err)
+	}
+	cleanup := func() {
+		if err := db.Close(); err != nil {
+			l.Warn("close migrate database failed", logger.Error(err))
+		}
+	}
+	return newMigrator(db, cfg.Migrate, l), cleanup, nil
+}
+
+func newMigrator(db *sql.DB, cfg MigrateConfig, l logger.Logger) *migrate.Migrator {
+	fsys, err := fs.Sub(dao.Migrations, "migrations")
+	if err != nil {
+		panic(err)
+	}
+	migrations, err := migrate.Load(fsys)
+	if err != nil {
+		panic(err)
+	}
+	return migrate.NewMigrator(db, migrations, cfg.LockTimeout, l)
+}
+
+// checkSchema applies the pending migrations when db.migrate.auto is on and
+// refuses to start on a schema older than the binary
+func checkSchema(db *sql.DB, l logger.Logger) {
+	cfg := defaultConfig().DB.Migrate
+	err := viper.UnmarshalKey("db.migrate", &cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	m := newMigrator(db, cfg, l)
+	ctx := context.Background()
+	if cfg.Auto {
+		if _, err := m.Up(ctx); err != nil {
+			panic(err)
+		}
+	}
+	if err := m.Check(ctx); err != nil {
+		l.Error("schema is not up to date, run `connectify migrate up`", logger.Error(err))
+		panic(err)
+	}
+}
@@ -0,0 +1,23 @@
+package ioc
+
+import (
+	"testing"
+
+	"github.com/cyvqet/connectify/pkg/logger"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitMigrator_Unreachable(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	// Nothing listens on port 1
+	viper.Set("db.mysql.dsn", "root:root@tcp(127.0.0.1:1)/connectify")
+
+	m, cleanup, err := InitMigrator(logger.NewNopLogger())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connect to mysql")
+	assert.Nil(t, m)
+	assert.Nil(t, cleanup)
+}
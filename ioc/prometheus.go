@@ -0,0 +1,11 @@
+package ioc
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// InitPrometheusRegisterer is where every collector is registered, /metrics
+// serves the default registry
+func InitPrometheusRegisterer() prometheus.Registerer {
+	return prometheus.DefaultRegisterer
+}
@@ -0,0 +1,307 @@
+package ioc
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/cyvqet/connectify/internal/errs"
+	"github.com/cyvqet/connectify/internal/i18n"
+	"github.com/cyvqet/connectify/internal/web"
+	"github.com/cyvqet/connectify/pkg/ginx"
+	"github.com/cyvqet/connectify/pkg/logger"
+	"github.com/cyvqet/connectify/pkg/middleware/ratelimit"
+	"github.com/cyvqet/connectify/pkg/prometheusx"
+	limiter "github.com/cyvqet/connectify/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+type limiterConfig struct {
+	// slide_window (default), fixed_window, token_bucket or gcra
+	Algorithm string        `yaml:"algorithm"`
+	Interval  time.Duration `yaml:"interval"`
+	Rate      int           `yaml:"rate"`
+	// Only for token_bucket and gcra, defaults to rate
+	Burst int `yaml:"burst"`
+}
+
+type ruleConfig struct {
+	Name      string        `yaml:"name"`
+	Methods   []string      `yaml:"methods"`
+	Path      string        `yaml:"path"`
+	Key       string        `yaml:"key"`
+	Algorithm string        `yaml:"algorithm"`
+	Interval  time.Duration `yaml:"interval"`
+	Rate      int           `yaml:"rate"`
+	Burst     int           `yaml:"burst"`
+}
+
+// RateLimitRules owns the Gin rate limit rules and applies changes of
+// ratelimit.* in the config file without restart. An invalid new config is
+// rejected and the previous rules are kept.
+type RateLimitRules struct {
+	redisClient redis.Cmdable
+	fallbacks   *prometheus.CounterVec
+	l           logger.Logger
+	builder     *ratelimit.Builder
+	effective   atomic.Pointer[rateLimitConfig]
+	// limiters of the running rules by name, only touched by load callers
+	limiters map[string]ruleLimiter
+}
+
+// ruleLimiter is the limiter of a rule and what it was built from. A reload
+// keeps it while both stay the same, so its local fallback keeps its state.
+type ruleLimiter struct {
+	cfg      limiterConfig
+	fallback string
+	limiter  limiter.Limiter
+}
+
+// rateLimitConfig is the config the running rules were built from
+type rateLimitConfig struct {
+	Fallback string       `yaml:"fallback"`
+	Rules    []ruleConfig `yaml:"rules"`
+}
+
+func InitRateLimitRules(redisClient redis.Cmdable, reg prometheus.Registerer, l logger.Logger) *RateLimitRules {
+	r := &RateLimitRules{
+		redisClient: redisClient,
+		fallbacks:   fallbackCounter(reg),
+		l:           l,
+	}
+	cfg, rules, limiters, err := r.load()
+	if err != nil {
+		panic(err)
+	}
+	r.limiters = limiters
+	r.builder = ratelimit.NewRuleBuilder(rules).
+		KeyFunc("user", userKey).
+		OnReject(rejectRateLimited).
+		Logger(l)
+	r.effective.Store(&cfg)
+
+	onConfigChange(r.reload)
+	return r
+}
+
+func rejectRateLimited(ctx *gin.Context, status int) {
+	code := errs.RateLimited
+	if status != http.StatusTooManyRequests {
+		code = errs.Internal
+	}
+	ginx.Abort(ctx, web.Result(ctx, code))
+}
+
+// Middleware must run after the JWT middleware so rules can key by user
+func (r *RateLimitRules) Middleware() gin.HandlerFunc {
+	return r.builder.Build()
+}
+
+// Handler shows the rules currently in effect
+func (r *RateLimitRules) Handler(ctx *gin.Context) {
+	type RuleVo struct {
+		Name      string   `json:"name"`
+		Methods   []string `json:"methods"`
+		Path      string   `json:"path"`
+		Key       string   `json:"key"`
+		Algorithm string   `json:"algorithm"`
+		Interval  string   `json:"interval"`
+		Rate      int      `json:"rate"`
+		Burst     int      `json:"burst"`
+	}
+	effective := r.effective.Load()
+	vos := make([]RuleVo, 0, len(effective.Rules))
+	for _, cfg := range effective.Rules {
+		vos = append(vos, RuleVo{
+			Name:      cfg.Name,
+			Methods:   cfg.Methods,
+			Path:      cfg.Path,
+			Key:       cfg.Key,
+			Algorithm: cmp.Or(cfg.Algorithm, "slide_window"),
+			Interval:  cfg.Interval.String(),
+			Rate:      cfg.Rate,
+			Burst:     cmp.Or(cfg.Burst, cfg.Rate),
+		})
+	}
+	ginx.Render(ctx, ginx.Result{Msg: i18n.Msg(web.Lang(ctx), errs.OK), Data: gin.H{
+		"fallback": effective.Fallback,
+		"rules":    vos,
+	}})
+}
+
+func (r *RateLimitRules) reload() {
+	cfg, rules, limiters, err := r.load()
+	if err == nil {
+		err = r.builder.SetRules(rules)
+	}
+	if err != nil {
+		r.l.Error("invalid rate limit config, keeping previous rules", logger.Error(err))
+		return
+	}
+	r.limiters = limiters
+	r.effective.Store(&cfg)
+	r.l.Info("rate limit rules reloaded", logger.Int("rules", len(rules)))
+}
+
+// load builds the rules of the config file, reusing the limiters of the
+// running rules whose limiter config did not change
+func (r *RateLimitRules) load() (rateLimitConfig, []ratelimit.Rule, map[string]ruleLimiter, error) {
+	var cfgs []ruleConfig
+	err := viper.UnmarshalKey("ratelimit.rules", &cfgs)
+	if err != nil {
+		return rateLimitConfig{}, nil, nil, err
+	}
+	// Also catches a config file caught half written
+	if len(cfgs) == 0 {
+		return rateLimitConfig{}, nil, nil, fmt.Errorf("ratelimit.rules is empty")
+	}
+
+	fallback := viper.GetString("ratelimit.fallback")
+	rules := make([]ratelimit.Rule, 0, len(cfgs))
+	limiters := make(map[string]ruleLimiter, len(cfgs))
+	for _, cfg := range cfgs {
+		lc := limiterConfig{
+			Algorithm: cfg.Algorithm,
+			Interval:  cfg.Interval,
+			Rate:      cfg.Rate,
+			Burst:     cfg.Burst,
+		}
+		rl, ok := r.limiters[cfg.Name]
+		if !ok || rl.cfg != lc || rl.fallback != fallback {
+			lim, err := newLimiter(r.redisClient, lc, r.fallbacks.WithLabelValues(cfg.Name), r.l)
+			if err != nil {
+				return rateLimitConfig{}, nil, nil, fmt.Errorf("rate limit rule %q: %w", cfg.Name, err)
+			}
+			rl = ruleLimiter{cfg: lc, fallback: fallback, limiter: lim}
+		}
+		limiters[cfg.Name] = rl
+		rules = append(rules, ratelimit.Rule{
+			Name:    cfg.Name,
+			Methods: cfg.Methods,
+			Path:    cfg.Path,
+			Key:     cfg.Key,
+			Limiter: rl.limiter,
+		})
+	}
+	return rateLimitConfig{
+		Fallback: fallback,
+		Rules:    cfgs,
+	}, rules, limiters, nil
+}
+
+// userKey limits by the user of the JWT set by the login middleware
+func userKey(ctx *gin.Context) (string, bool) {
+	claimAny, ok := ctx.Get("claim")
+	if !ok {
+		return "", false
+	}
+	claim, ok := claimAny.(web.UserClaims)
+	if !ok {
+		return "", false
+	}
+	if claim.UserId != 0 {
+		return strconv.FormatInt(claim.UserId, 10), true
+	}
+	// Tokens issued by email login carry no id
+	return claim.UserEmail, claim.UserEmail != ""
+}
+
+// fallbackCounter counts the requests decided by the fallback, by rule.
+// Rules of the config file are labelled by name, other limiters by config key.
+func fallbackCounter(reg prometheus.Registerer) *prometheus.CounterVec {
+	return prometheusx.MustRegister(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "connectify",
+		Subsystem: "ratelimit",
+		Name:      "fallbacks_total",
+		Help:      "Requests rate limited by the fallback because Redis failed",
+	}, []string{"rule"}))
+}
+
+// newLimiter returns a Redis limiter that falls back according to
+// ratelimit.fallback (local, open or closed) when Redis errors
+func newLimiter(redisClient redis.Cmdable, cfg limiterConfig, fallbacks prometheus.Counter,
+	l logger.Logger) (limiter.Limiter, error) {
+	mode, err := limiter.ParseFallbackMode(viper.GetString("ratelimit.fallback"))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Interval <= 0 || cfg.Rate <= 0 {
+		return nil, fmt.Errorf("interval and rate must be positive")
+	}
+	burst := cfg.Burst
+	if burst <= 0 {
+		burst = cfg.Rate
+	}
+
+	var primary limiter.Limiter
+	switch cfg.Algorithm {
+	case "", "slide_window":
+		primary = limiter.NewRedisSlideWindowLimiter(redisClient, cfg.Interval, cfg.Rate)
+	case "fixed_window":
+		primary = limiter.NewRedisFixedWindowLimiter(redisClient, cfg.Interval, cfg.Rate)
+	case "token_bucket":
+		primary = limiter.NewRedisTokenBucketLimiter(redisClient, cfg.Interval, cfg.Rate, burst)
+	case "gcra":
+		primary = limiter.NewRedisGCRALimiter(redisClient, cfg.Interval, cfg.Rate, burst)
+	default:
+		return nil, fmt.Errorf("unknown rate limit algorithm %q", cfg.Algorithm)
+	}
+
+	return limiter.NewFallbackLimiter(
+		primary,
+		limiter.NewLocalTokenBucketLimiter(cfg.Interval, cfg.Rate, burst),
+		mode,
+		fallbacks,
+		l,
+	), nil
+}
+
+// newReloadableLimiter builds a limiter from the config at key and swaps
+// in a new one whenever that config changes
+func newReloadableLimiter(redisClient redis.Cmdable, key string, reg prometheus.Registerer,
+	l logger.Logger) limiter.Limiter {
+	fallbacks := fallbackCounter(reg).WithLabelValues(key)
+	var cur ruleLimiter
+	load := func() (ruleLimiter, error) {
+		var cfg limiterConfig
+		if err := viper.UnmarshalKey(key, &cfg); err != nil {
+			return ruleLimiter{}, err
+		}
+		fallback := viper.GetString("ratelimit.fallback")
+		// Other keys changed, keep the limiter and the state of its fallback
+		if cur.limiter != nil && cur.cfg == cfg && cur.fallback == fallback {
+			return cur, nil
+		}
+		lim, err := newLimiter(redisClient, cfg, fallbacks, l)
+		if err != nil {
+			return ruleLimiter{}, err
+		}
+		return ruleLimiter{cfg: cfg, fallback: fallback, limiter: lim}, nil
+	}
+
+	cur, err := load()
+	if err != nil {
+		panic(fmt.Errorf("%s: %w", key, err))
+	}
+	res := limiter.NewReloadableLimiter(cur.limiter)
+	onConfigChange(func() {
+		next, err := load()
+		if err != nil {
+			l.Error("invalid rate limit config, keeping previous limiter",
+				logger.String("key", key), logger.Error(err))
+			return
+		}
+		if next.limiter != cur.limiter {
+			cur = next
+			res.Swap(cur.limiter)
+		}
+	})
+	return res
+}
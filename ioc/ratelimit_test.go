@@ -0,0 +1,40 @@
+package ioc
+
+import (
+	"testing"
+
+	"github.com/cyvqet/connectify/pkg/logger"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitRules_Reload(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	rule := func(name string, rate int) map[string]any {
+		return map[string]any{"name": name, "path": "/user/*", "key": "ip", "interval": "1m", "rate": rate}
+	}
+	viper.Set("ratelimit.fallback", "local")
+	viper.Set("ratelimit.rules", []map[string]any{rule("login", 5), rule("signup", 5)})
+
+	mr := miniredis.RunT(t)
+	r := InitRateLimitRules(redis.NewClient(&redis.Options{Addr: mr.Addr()}),
+		prometheus.NewRegistry(), logger.NewNopLogger())
+	before := r.builder.Rules()
+
+	viper.Set("ratelimit.rules", []map[string]any{rule("login", 5), rule("signup", 10)})
+	r.reload()
+	after := r.builder.Rules()
+	require.Len(t, after, 2)
+	// Only the changed rule gets a new limiter, with an empty local fallback
+	assert.Same(t, before[0].Limiter, after[0].Limiter)
+	assert.NotSame(t, before[1].Limiter, after[1].Limiter)
+
+	viper.Set("ratelimit.fallback", "open")
+	r.reload()
+	assert.NotSame(t, after[0].Limiter, r.builder.Rules()[0].Limiter)
+}
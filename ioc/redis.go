@@ -1,22 +1,34 @@
 package ioc
 
 import (
+	"context"
+
+	"github.com/cyvqet/connectify/pkg/redisx"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/redis/go-redis/v9"
 )
 
-func InitRedis() redis.Cmdable {
-	type RedisConfig struct {
-		Addr string `yaml:"addr"`
-	}
+func InitRedis(tp trace.TracerProvider, reg prometheus.Registerer) redis.Cmdable {
 	var redisConfig RedisConfig
 	err := viper.UnmarshalKey("redis", &redisConfig)
 	if err != nil {
 		panic(err)
 	}
 
-	return redis.NewClient(&redis.Options{
+	client := redis.NewClient(&redis.Options{
 		Addr: redisConfig.Addr,
 	})
+	client.AddHook(redisx.NewPrometheusHook(reg, "connectify", "redis"))
+	client.AddHook(redisx.NewTracingHook(tp))
+	// Not critical, user reads and logins keep working in degraded mode
+	registerReadinessCheck("redis", false, func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	})
+	onShutdown(phaseClients, "redis", func(context.Context) error {
+		return client.Close()
+	})
+	return client
 }
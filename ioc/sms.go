@@ -1,56 +1,131 @@
 package ioc
 
 import (
-	"time"
-
+	"github.com/cyvqet/connectify/internal/repository"
+	"github.com/cyvqet/connectify/internal/service"
 	"github.com/cyvqet/connectify/internal/service/sms"
 	"github.com/cyvqet/connectify/internal/service/sms/aliyun"
+	"github.com/cyvqet/connectify/internal/service/sms/audit"
+	"github.com/cyvqet/connectify/internal/service/sms/auth"
 	"github.com/cyvqet/connectify/internal/service/sms/failover"
+	"github.com/cyvqet/connectify/internal/service/sms/metrics"
 	"github.com/cyvqet/connectify/internal/service/sms/ratelimit"
 	"github.com/cyvqet/connectify/internal/service/sms/tencent"
+	"github.com/cyvqet/connectify/internal/service/sms/tracing"
+	"github.com/cyvqet/connectify/internal/web"
+	"github.com/cyvqet/connectify/pkg/ginx"
+	"github.com/cyvqet/connectify/pkg/logger"
 	limiter "github.com/cyvqet/connectify/pkg/ratelimit"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/viper"
+
 	"github.com/redis/go-redis/v9"
 )
 
 // It directly returns a single provider (Tencent Cloud SMS) without
 // any additional protection such as rate limiting or failover.
-func InitSmsService(redisClient redis.Cmdable) sms.Service {
+// Every send is recorded in the SMS audit log.
+func InitSmsService(redisClient redis.Cmdable, repo repository.SMSLogRepository, reg prometheus.Registerer,
+	l logger.Logger) sms.Service {
 	// Simple and direct provider usage.
 	// Suitable for demos or scenarios where high availability is not required.
-	return tencent.NewService("appId", "signName")
+	return audit.NewService(newTencentSMS(reg, l), repo, l)
 }
 
 // Before sending an SMS, the rate limiter is checked.
 // If the rate limit is exceeded, the request is rejected immediately.
-func InitSmsRatelimitService(redisClient redis.Cmdable) sms.Service {
+func InitSmsRatelimitService(redisClient redis.Cmdable, reg prometheus.Registerer, l logger.Logger) sms.Service {
 	return ratelimit.NewService(
 		// The actual SMS provider implementation
-		tencent.NewService("appId", "signName"),
+		newTencentSMS(reg, l),
 
-		// Redis-based sliding window rate limiter
-		// Allows up to 100 requests per minute (globally)
-		limiter.NewRedisSlideWindowLimiter(redisClient, time.Minute, 100),
+		// Redis-based rate limiter, e.g. up to 100 requests per minute (globally)
+		// Thresholds come from sms.ratelimit and are reloaded on config change
+		newReloadableLimiter(redisClient, "sms.ratelimit", reg, l),
 	)
 }
 
 // This function wraps multiple SMS providers with a failover strategy.
 // Providers are tried one by one until one succeeds or all fail.
-func InitSmsFailoverService(redisClient redis.Cmdable) sms.Service {
+func InitSmsFailoverService(redisClient redis.Cmdable, reg prometheus.Registerer, l logger.Logger) sms.Service {
 	return failover.NewService(
 		[]sms.Service{
-			tencent.NewService("appId", "signName"),
-			aliyun.NewService("appId", "signName"),
+			newTencentSMS(reg, l),
+			newAliyunSMS(reg, l),
 		},
 	)
 }
 
 // This function wraps multiple SMS providers with a timeout-based failover strategy.
-func InitSmsFailoverTimeoutService(redisClient redis.Cmdable) sms.Service {
+func InitSmsFailoverTimeoutService(redisClient redis.Cmdable, reg prometheus.Registerer, l logger.Logger) sms.Service {
 	return failover.NewTimeoutService(
 		[]sms.Service{
-			tencent.NewService("appId", "signName"),
-			aliyun.NewService("appId", "signName"),
+			newTencentSMS(reg, l),
+			newAliyunSMS(reg, l),
 		},
 	)
 }
+
+// InitSMSHandler builds the internal SMS API used by other teams.
+// Callers present a signed service token; each business is restricted to
+// its whitelisted templates and its own send quota.
+func InitSMSHandler(redisClient redis.Cmdable, repo repository.SMSLogRepository, w *ginx.Wrapper,
+	reg prometheus.Registerer, l logger.Logger) *web.SMSHandler {
+	var cfg SMSInternalConfig
+	err := viper.UnmarshalKey("sms.internal", &cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	policies := make(map[string]auth.Policy, len(cfg.Businesses))
+	for biz, bc := range cfg.Businesses {
+		policies[biz] = auth.Policy{
+			Templates: bc.Templates,
+			Limiter:   limiter.NewRedisSlideWindowLimiter(redisClient, bc.Interval, bc.Rate),
+		}
+	}
+
+	svc := auth.NewService(
+		audit.NewService(
+			failover.NewService([]sms.Service{
+				newTencentSMS(reg, l),
+				newAliyunSMS(reg, l),
+			}),
+			repo,
+			l,
+		),
+		[]byte(cfg.Key),
+		policies,
+		l,
+	)
+	return web.NewSMSHandler(svc, w)
+}
+
+// Providers are wrapped one by one so metrics and spans tell them apart behind a failover
+func newTencentSMS(reg prometheus.Registerer, l logger.Logger) sms.Service {
+	registerSMSReachability("tencent")
+	cfg := smsProviderConfig("tencent")
+	return tracing.NewService(metrics.NewService(tencent.NewService(cfg.AppId, cfg.SignName, l), "tencent", reg), "tencent")
+}
+
+func newAliyunSMS(reg prometheus.Registerer, l logger.Logger) sms.Service {
+	registerSMSReachability("aliyun")
+	cfg := smsProviderConfig("aliyun")
+	return tracing.NewService(metrics.NewService(aliyun.NewService(cfg.AppId, cfg.SignName, l), "aliyun", reg), "aliyun")
+}
+
+// smsProviderConfig reads the account of provider from sms.providers
+func smsProviderConfig(provider string) SMSProviderConfig {
+	var cfg SMSProviderConfig
+	err := viper.UnmarshalKey("sms.providers."+provider, &cfg)
+	if err != nil {
+		panic(err)
+	}
+	return cfg
+}
+
+// InitSMSLogHandler accepts the delivery receipts signed with sms.receipt.secret
+func InitSMSLogHandler(svc service.SMSLogService, w *ginx.Wrapper) *web.SMSLogHandler {
+	return web.NewSMSLogHandler(svc, []byte(viper.GetString("sms.receipt.secret")), w)
+}
@@ -0,0 +1,76 @@
+package ioc
+
+import (
+	"context"
+	"os"
+
+	"github.com/cyvqet/connectify/pkg/logger"
+
+	"github.com/spf13/viper"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// InitTracerProvider also installs the provider and the W3C propagators
+// globally, for code that gets its tracer from otel.Tracer
+func InitTracerProvider(l logger.Logger) *sdktrace.TracerProvider {
+	cfg := defaultConfig().Trace
+	err := viper.UnmarshalKey("trace", &cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+	))
+	if err != nil {
+		panic(err)
+	}
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		// Follow the caller's decision so a trace is never half sampled
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	}
+
+	switch cfg.Exporter {
+	case "otlp":
+		clientOpts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.OTLP.Endpoint)}
+		if cfg.OTLP.Insecure {
+			clientOpts = append(clientOpts, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(context.Background(), clientOpts...)
+		if err != nil {
+			panic(err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	case "stdout":
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			panic(err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	case "none":
+		// Spans are still created so trace ids reach the logs, they are just not exported
+	default:
+		panic("unknown trace exporter: " + cfg.Exporter)
+	}
+
+	// Export failures would otherwise go to the standard log
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		l.Warn("opentelemetry error", logger.Error(err))
+	}))
+	tp := sdktrace.NewTracerProvider(opts...)
+	// Exports the spans still batched, before the logger is flushed
+	onShutdown(phaseTelemetry, "tracer", tp.Shutdown)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+	return tp
+}
@@ -0,0 +1,31 @@
+package ioc
+
+import (
+	"fmt"
+
+	"github.com/cyvqet/connectify/internal/service/voice"
+	"github.com/cyvqet/connectify/internal/service/voice/local"
+	"github.com/cyvqet/connectify/internal/service/voice/tencent"
+	"github.com/cyvqet/connectify/pkg/logger"
+
+	"github.com/spf13/viper"
+)
+
+// InitVoiceService picks the provider from voice.provider.
+// local only logs the calls, for development without a voice account.
+func InitVoiceService(l logger.Logger) voice.Service {
+	var cfg VoiceConfig
+	err := viper.UnmarshalKey("voice", &cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	switch cfg.Provider {
+	case "tencent":
+		return tencent.NewService(cfg.Providers.Tencent.AppId, l)
+	case "local":
+		return local.NewService(l)
+	default:
+		panic(fmt.Sprintf("unknown voice provider %q", cfg.Provider))
+	}
+}
@@ -0,0 +1,26 @@
+package ioc
+
+import (
+	"testing"
+
+	"github.com/cyvqet/connectify/internal/service/voice/local"
+	"github.com/cyvqet/connectify/internal/service/voice/tencent"
+	"github.com/cyvqet/connectify/pkg/logger"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitVoiceService(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	viper.Set("voice.providers.tencent.appId", "app-1")
+
+	viper.Set("voice.provider", "tencent")
+	assert.IsType(t, &tencent.Service{}, InitVoiceService(logger.NewNopLogger()))
+
+	viper.Set("voice.provider", "local")
+	assert.IsType(t, &local.Service{}, InitVoiceService(logger.NewNopLogger()))
+
+	viper.Set("voice.provider", "")
+	assert.Panics(t, func() { InitVoiceService(logger.NewNopLogger()) })
+}
@@ -3,37 +3,72 @@ package ioc
 import (
 	"time"
 
+	"github.com/cyvqet/connectify/internal/repository/cache"
 	"github.com/cyvqet/connectify/internal/web"
 	"github.com/cyvqet/connectify/internal/web/middleware"
-	"github.com/cyvqet/connectify/pkg/middleware/ratelimit"
-	limiter "github.com/cyvqet/connectify/pkg/ratelimit"
+	"github.com/cyvqet/connectify/pkg/ginx"
+	"github.com/cyvqet/connectify/pkg/logger"
+	"github.com/cyvqet/connectify/pkg/middleware/accesslog"
+	prommdl "github.com/cyvqet/connectify/pkg/middleware/prometheus"
+	"github.com/cyvqet/connectify/pkg/middleware/requestid"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/redis/go-redis/v9"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 )
 
-func InitWebServer(mdls []gin.HandlerFunc, userHdl *web.UserHandler) *gin.Engine {
-	server := gin.Default()
+// InitJWTHandler signs login tokens with jwt.key
+func InitJWTHandler() *web.JWTHandler {
+	return web.NewJWTHandler([]byte(viper.GetString("jwt.key")))
+}
+
+func InitWebServer(mdls []gin.HandlerFunc, userHdl *web.UserHandler, smsHdl *web.SMSHandler,
+	smsLogHdl *web.SMSLogHandler, rlRules *RateLimitRules, redisHealth *cache.RedisHealthChecker,
+	codeStore CodeStore, w *ginx.Wrapper, logLevel zap.AtomicLevel) *gin.Engine {
+	// Requests are logged by the access log middleware instead of gin's logger
+	server := gin.New()
+	server.Use(gin.Recovery())
 	server.Use(mdls...)
 	userHdl.RegisterRouter(server)
+	smsHdl.RegisterRouter(server)
+	smsLogHdl.RegisterRouter(server)
+	server.GET("/admin/ratelimit/rules", rlRules.Handler)
+	server.GET("/admin/degrade/status", degradeStatusHandler(redisHealth, codeStore.Health))
+	server.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	logLevelHandlers(server, logLevel, w)
+	healthHandlers(server)
 	return server
 }
 
-func InitGinMiddlewares(redisClient redis.Cmdable) []gin.HandlerFunc {
+func InitGinMiddlewares(rlRules *RateLimitRules, jwtHdl *web.JWTHandler, tp trace.TracerProvider,
+	reg prometheus.Registerer, l logger.Logger) []gin.HandlerFunc {
 	return []gin.HandlerFunc{
+		// First, so requests rejected by later middlewares are measured too
+		prommdl.NewBuilder(reg, "connectify", "http").Build(),
+		// Root span of the request, handlers pass ctx.Request.Context() down
+		otelgin.Middleware("connectify", otelgin.WithTracerProvider(tp)),
+		requestid.NewBuilder().Build(),
+		// After the request id and the span, so entries carry both
+		initAccessLog(l),
+
 		cors.New(cors.Config{
 			// List of allowed origins for CORS
 			AllowOrigins: []string{"https://foo.com"},
 
 			// Allowed HTTP methods for CORS requests
-			AllowMethods: []string{"GET", "POST"},
+			AllowMethods: []string{"GET", "POST", "PUT"},
 
 			// Request headers that the browser is allowed to send
 			AllowHeaders: []string{"Origin", "Authorization", "Content-Type"},
 
 			// Response headers that can be accessed by frontend JavaScript
-			ExposeHeaders: []string{"Jwt-Token"},
+			ExposeHeaders: []string{"Jwt-Token",
+				"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After"},
 
 			// Whether to allow credentials such as cookies or Authorization headers
 			// Note: when enabled, AllowOrigins cannot be "*"
@@ -50,16 +85,55 @@ func InitGinMiddlewares(redisClient redis.Cmdable) []gin.HandlerFunc {
 			MaxAge: 12 * time.Hour,
 		}),
 
-		// Rate limiting: allow up to 100 requests per minute
-		ratelimit.NewBuilder(limiter.NewRedisSlideWindowLimiter(redisClient, time.Minute, 100)).Build(),
-
 		// JWT login middleware
 		// Ignore authentication for the following paths
-		middleware.NewLoginJwtMiddlewareBuilder().
+		middleware.NewLoginJwtMiddlewareBuilder(jwtHdl, l).
 			IgnorePath("/user/login_jwt").
 			IgnorePath("/user/signup").
 			IgnorePath("/user/send_sms_code").
 			IgnorePath("/user/login_sms").
+			// Internal callers authenticate with a service token instead
+			IgnorePath("/internal/sms/send").
+			// Delivery receipts pushed by SMS providers
+			IgnorePath("/sms/receipt").
+			// Scraped by Prometheus
+			IgnorePath("/metrics").
+			// Probed by Kubernetes
+			IgnorePath("/healthz").
+			IgnorePath("/readyz").
 			Build(),
+		// Every /admin route, after the JWT middleware identified the user
+		initAdmin(l),
+
+		// Rate limiting: per-route rules from config, see ratelimit.rules
+		rlRules.Middleware(),
+	}
+}
+
+func initAdmin(l logger.Logger) gin.HandlerFunc {
+	var cfg AdminConfig
+	err := viper.UnmarshalKey("admin", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	return middleware.NewAdminMiddlewareBuilder("/admin/", l).Admins(cfg.UserIds...).Build()
+}
+
+func initAccessLog(l logger.Logger) gin.HandlerFunc {
+	cfg := defaultConfig().AccessLog
+	err := viper.UnmarshalKey("accesslog", &cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	b := accesslog.NewBuilder(l).MaxBodySize(cfg.MaxBodySize).
+		MaskFields(cfg.MaskFields...).
+		SkipBodies(cfg.SkipBodyRoutes...)
+	if cfg.ReqBody {
+		b.AllowReqBody()
+	}
+	if cfg.RespBody {
+		b.AllowRespBody()
 	}
+	return b.Build()
 }
@@ -0,0 +1,79 @@
+package ioc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cyvqet/connectify/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// accessLogger keeps the fields of every access entry
+type accessLogger struct {
+	logger.NopLogger
+	entries *[]map[string]any
+}
+
+func (l accessLogger) Info(msg string, args ...logger.Field) {
+	entry := map[string]any{}
+	for _, f := range args {
+		entry[f.Key] = f.Val
+	}
+	*l.entries = append(*l.entries, entry)
+}
+
+func (l accessLogger) WithContext(ctx context.Context) logger.Logger {
+	return l
+}
+
+func TestInitAccessLog_CredentialRoutes(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	// Bodies on as in config/dev.yaml, skipBodyRoutes left to the default
+	viper.Set("accesslog.reqBody", true)
+	viper.Set("accesslog.respBody", true)
+	viper.Set("accesslog.maskFields", []string{"phone"})
+
+	testCases := []struct {
+		name  string
+		route string
+
+		wantBodies bool
+	}{
+		{name: "send_sms_code", route: "/user/send_sms_code"},
+		{name: "login_sms", route: "/user/login_sms"},
+		{name: "login", route: "/user/login"},
+		{name: "other routes keep their bodies", route: "/user/profile", wantBodies: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var entries []map[string]any
+			gin.SetMode(gin.TestMode)
+			server := gin.New()
+			server.Use(initAccessLog(accessLogger{entries: &entries}))
+			server.POST(tc.route, func(ctx *gin.Context) {
+				// Whatever a handler puts in its response stays out of the log
+				ctx.JSON(http.StatusOK, gin.H{"code": 0, "msg": "ok", "data": "123456"})
+			})
+
+			req := httptest.NewRequest(http.MethodPost, tc.route,
+				strings.NewReader(`{"phone":"13800138000","code":"123456"}`))
+			server.ServeHTTP(httptest.NewRecorder(), req)
+
+			require.Len(t, entries, 1)
+			if !tc.wantBodies {
+				assert.NotContains(t, entries[0], "reqBody")
+				assert.NotContains(t, entries[0], "respBody")
+				return
+			}
+			assert.Equal(t, `{"phone":"***","code":"123456"}`, entries[0]["reqBody"])
+			assert.Equal(t, `{"code":0,"data":"123456","msg":"ok"}`, entries[0]["respBody"])
+		})
+	}
+}
@@ -2,49 +2,54 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
 
-	"github.com/spf13/viper"
-	"go.uber.org/zap"
+	"github.com/cyvqet/connectify/ioc"
 )
 
 func main() {
-	initLog()
-	initConfig() // initialize config
-	server := InitWebServer()
-	server.Run(":8080")
-}
+	// method 1: get environment type from command line parameter
+	envFlag := flag.String("env", "", "environment: dev or k8s (default: dev)")
+	printConfig := flag.Bool("print-config", false, "print the effective config with secrets redacted and exit")
+	flag.Parse()
 
-func initLog() {
-	logger, err := zap.NewDevelopment()
+	cfg, err := initConfig(*envFlag) // initialize config
 	if err != nil {
-		panic(err)
+		fmt.Fprintf(os.Stderr, "invalid config:\n%v\n", err)
+		os.Exit(1)
+	}
+	if *printConfig {
+		if err := ioc.PrintConfig(os.Stdout, cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	zap.ReplaceGlobals(logger)
-}
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 
-func initConfig() {
-	// method 1: get environment type from command line parameter
-	envFlag := flag.String("env", "", "environment: dev or k8s (default: dev)")
-	flag.Parse()
+	app := InitApp()
+	if err := app.Run(); err != nil {
+		// The logger may be flushed already, stderr still gets it
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
 
-	var env string
-	if *envFlag != "" {
-		env = *envFlag
-	} else {
+func initConfig(env string) (ioc.Config, error) {
+	if env == "" {
 		// method 2: get environment type from environment variable
 		env = os.Getenv("ENV")
 		if env == "" {
 			env = "dev" // default use dev environment
 		}
 	}
-
-	viper.SetConfigName(env)      // config file name (dev or k8s)
-	viper.SetConfigType("yaml")   // config file type
-	viper.AddConfigPath("config") // config file path
-	err := viper.ReadInConfig()
-	if err != nil {
-		panic(err)
-	}
+	return ioc.LoadConfig(env)
 }
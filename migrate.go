@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = "usage: connectify [-env dev|k8s] migrate up | down [steps] | status"
+
+// runMigrate runs the migrate subcommand with the arguments following it
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	m, cleanup, err := InitMigrator()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	switch args[0] {
+	case "up":
+		n, err := m.Up(ctx)
+		fmt.Printf("applied %d migrations\n", n)
+		return err
+	case "down":
+		// One at a time unless asked for more, reverting drops data
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("steps must be a positive number, got %q", args[1])
+			}
+		}
+		n, err := m.Down(ctx, steps)
+		fmt.Printf("reverted %d migrations\n", n)
+		return err
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range statuses {
+			status, appliedAt := "pending", ""
+			if s.Applied {
+				status, appliedAt = "applied", s.AppliedAt.Format(time.RFC3339)
+			}
+			if s.Dirty {
+				status = "dirty"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, status, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return errors.New(migrateUsage)
+	}
+}
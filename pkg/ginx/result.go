@@ -0,0 +1,35 @@
+package ginx
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Result is the envelope of every API response. Code 0 means success,
+// error codes are 6 digits starting with the HTTP status they are sent with,
+// e.g. 429002 is sent as 429 Too Many Requests.
+type Result struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data any    `json:"data,omitempty"`
+}
+
+// Status is the HTTP status the result is sent with
+func (r Result) Status() int {
+	status := r.Code / 1000
+	if status < 400 || status > 599 {
+		return http.StatusOK
+	}
+	return status
+}
+
+// Render writes res with the status derived from its code
+func Render(ctx *gin.Context, res Result) {
+	ctx.JSON(res.Status(), res)
+}
+
+// Abort writes res and stops the handler chain, for middlewares
+func Abort(ctx *gin.Context, res Result) {
+	ctx.AbortWithStatusJSON(res.Status(), res)
+}
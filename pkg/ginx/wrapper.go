@@ -0,0 +1,56 @@
+package ginx
+
+import (
+	"github.com/cyvqet/connectify/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Wrapper lets handlers return their result instead of writing the response
+type Wrapper struct {
+	l         logger.Logger
+	bindError func(ctx *gin.Context) Result
+}
+
+// NewWrapper logs the errors returned by handlers to l
+func NewWrapper(l logger.Logger) *Wrapper {
+	return &Wrapper{
+		l: l,
+		bindError: func(ctx *gin.Context) Result {
+			return Result{Code: 400000, Msg: "invalid request"}
+		},
+	}
+}
+
+// BindErrorResult answers request bodies that fail to bind, e.g. to localize the message
+func (w *Wrapper) BindErrorResult(fn func(ctx *gin.Context) Result) *Wrapper {
+	w.bindError = fn
+	return w
+}
+
+// Wrap turns fn into a gin handler.
+// A returned error is only logged, the client sees the result.
+func (w *Wrapper) Wrap(fn func(ctx *gin.Context) (Result, error)) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		res, err := fn(ctx)
+		if err != nil {
+			w.l.WithContext(ctx.Request.Context()).Error("handle request failed",
+				logger.String("method", ctx.Request.Method),
+				logger.String("route", ctx.FullPath()),
+				logger.Error(err))
+		}
+		Render(ctx, res)
+	}
+}
+
+// WrapBody is Wrap for handlers taking a JSON body. It is not a method of
+// Wrapper, methods cannot have type parameters.
+func WrapBody[Req any](w *Wrapper, fn func(ctx *gin.Context, req Req) (Result, error)) gin.HandlerFunc {
+	return w.Wrap(func(ctx *gin.Context) (Result, error) {
+		var req Req
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			return w.bindError(ctx), nil
+		}
+		return fn(ctx, req)
+	})
+}
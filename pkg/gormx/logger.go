@@ -0,0 +1,77 @@
+package gormx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cyvqet/connectify/pkg/logger"
+	"gorm.io/gorm"
+	glogger "gorm.io/gorm/logger"
+)
+
+// Logger reports failed statements and those slower than SlowThreshold
+// through l, with the ids of the statement context. Statements are logged
+// with placeholders, the values may be personal data.
+type Logger struct {
+	l             logger.Logger
+	level         glogger.LogLevel
+	slowThreshold time.Duration
+}
+
+// NewLogger logs statements slower than slowThreshold, 0 disables it
+func NewLogger(l logger.Logger, slowThreshold time.Duration) *Logger {
+	return &Logger{
+		l:             l,
+		level:         glogger.Warn,
+		slowThreshold: slowThreshold,
+	}
+}
+
+func (g *Logger) LogMode(level glogger.LogLevel) glogger.Interface {
+	res := *g
+	res.level = level
+	return &res
+}
+
+func (g *Logger) Info(ctx context.Context, msg string, args ...any) {
+	if g.level >= glogger.Info {
+		g.l.WithContext(ctx).Info(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (g *Logger) Warn(ctx context.Context, msg string, args ...any) {
+	if g.level >= glogger.Warn {
+		g.l.WithContext(ctx).Warn(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (g *Logger) Error(ctx context.Context, msg string, args ...any) {
+	if g.level >= glogger.Error {
+		g.l.WithContext(ctx).Error(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (g *Logger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if g.level <= glogger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && g.level >= glogger.Error:
+		sql, rows := fc()
+		g.l.WithContext(ctx).Error("sql failed", logger.Error(err), logger.String("sql", sql),
+			logger.Int64("rows", rows), logger.Duration("elapsed", elapsed))
+	case g.slowThreshold > 0 && elapsed > g.slowThreshold && g.level >= glogger.Warn:
+		sql, rows := fc()
+		g.l.WithContext(ctx).Warn("slow sql", logger.String("sql", sql),
+			logger.Int64("rows", rows), logger.Duration("elapsed", elapsed),
+			logger.Duration("threshold", g.slowThreshold))
+	}
+}
+
+// ParamsFilter drops the values, so the SQL passed to Trace keeps its placeholders
+func (g *Logger) ParamsFilter(ctx context.Context, sql string, params ...any) (string, []any) {
+	return sql, nil
+}
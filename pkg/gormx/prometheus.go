@@ -0,0 +1,96 @@
+package gormx
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cyvqet/connectify/pkg/prometheusx"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+)
+
+const startTimeKey = "prometheus:start_time"
+
+// PrometheusPlugin times every statement by table and operation
+type PrometheusPlugin struct {
+	Namespace string
+	Subsystem string
+
+	reg      prometheus.Registerer
+	duration *prometheus.HistogramVec
+}
+
+// NewPrometheusPlugin registers the histogram with reg on Initialize,
+// plugins of several databases share it
+func NewPrometheusPlugin(reg prometheus.Registerer, namespace, subsystem string) *PrometheusPlugin {
+	return &PrometheusPlugin{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		reg:       reg,
+	}
+}
+
+func (p *PrometheusPlugin) Name() string {
+	return "prometheus"
+}
+
+func (p *PrometheusPlugin) Initialize(db *gorm.DB) error {
+	duration, err := prometheusx.Register(p.reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: p.Namespace,
+		Subsystem: p.Subsystem,
+		Name:      "query_duration_seconds",
+		Help:      "Database statement latency",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"table", "operation", "result"}))
+	if err != nil {
+		return err
+	}
+	p.duration = duration
+
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("*").Register("prometheus:before_create", p.before),
+		cb.Create().After("*").Register("prometheus:after_create", p.after("create")),
+		cb.Query().Before("*").Register("prometheus:before_query", p.before),
+		cb.Query().After("*").Register("prometheus:after_query", p.after("query")),
+		cb.Update().Before("*").Register("prometheus:before_update", p.before),
+		cb.Update().After("*").Register("prometheus:after_update", p.after("update")),
+		cb.Delete().Before("*").Register("prometheus:before_delete", p.before),
+		cb.Delete().After("*").Register("prometheus:after_delete", p.after("delete")),
+		cb.Row().Before("*").Register("prometheus:before_row", p.before),
+		cb.Row().After("*").Register("prometheus:after_row", p.after("row")),
+		cb.Raw().Before("*").Register("prometheus:before_raw", p.before),
+		cb.Raw().After("*").Register("prometheus:after_raw", p.after("raw")),
+	)
+}
+
+func (p *PrometheusPlugin) before(db *gorm.DB) {
+	db.Set(startTimeKey, time.Now())
+}
+
+func (p *PrometheusPlugin) after(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		val, ok := db.Get(startTimeKey)
+		if !ok {
+			return
+		}
+		start, ok := val.(time.Time)
+		if !ok {
+			return
+		}
+		// Raw statements have no table, the table set is bounded by the models
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		result := "ok"
+		switch {
+		case errors.Is(db.Error, gorm.ErrRecordNotFound):
+			result = "not_found"
+		case db.Error != nil:
+			result = "error"
+		}
+		p.duration.WithLabelValues(table, operation, result).Observe(time.Since(start).Seconds())
+	}
+}
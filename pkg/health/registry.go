@@ -0,0 +1,137 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusUp Status = "up"
+	// StatusDegraded only non-critical checks failed, the service still works
+	StatusDegraded Status = "degraded"
+	StatusDown     Status = "down"
+)
+
+// Checker reports a dependency as down by returning an error
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// CheckResult is the outcome of one checker
+type CheckResult struct {
+	Status   Status `json:"status"`
+	Critical bool   `json:"critical"`
+	Error    string `json:"error,omitempty"`
+	// LatencyMs is how long the check took
+	LatencyMs int64 `json:"latencyMs"`
+}
+
+// Report is the outcome of every registered checker, Status is down
+// as soon as one critical checker fails
+type Report struct {
+	Status Status                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+type check struct {
+	checker  Checker
+	timeout  time.Duration
+	critical bool
+}
+
+// Registry runs the registered checkers concurrently, each within its own timeout
+type Registry struct {
+	mu     sync.RWMutex
+	checks map[string]check
+}
+
+func NewRegistry() *Registry {
+	return &Registry{checks: make(map[string]check)}
+}
+
+// Register adds a checker, replacing the one registered under name before.
+// A failing critical checker makes the whole report down.
+func (r *Registry) Register(name string, c Checker, timeout time.Duration, critical bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = check{checker: c, timeout: timeout, critical: critical}
+}
+
+func (r *Registry) Check(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := make(map[string]check, len(r.checks))
+	for name, c := range r.checks {
+		checks[name] = c
+	}
+	r.mu.RUnlock()
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		report = Report{Status: StatusUp, Checks: make(map[string]CheckResult, len(checks))}
+	)
+	for name, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := run(ctx, c)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = res
+			switch {
+			case res.Status == StatusUp:
+			case c.critical:
+				report.Status = StatusDown
+			case report.Status == StatusUp:
+				report.Status = StatusDegraded
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+func run(ctx context.Context, c check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				errCh <- fmt.Errorf("checker panicked: %v", p)
+			}
+		}()
+		errCh <- c.checker.Check(ctx)
+	}()
+
+	// A checker ignoring ctx must not hold up the whole report
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := CheckResult{
+		Status:    StatusUp,
+		Critical:  c.critical,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		res.Status = StatusDown
+		res.Error = err.Error()
+	}
+	return res
+}
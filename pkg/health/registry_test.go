@@ -0,0 +1,100 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_Check(t *testing.T) {
+	up := CheckerFunc(func(ctx context.Context) error { return nil })
+	down := CheckerFunc(func(ctx context.Context) error { return errors.New("connection refused") })
+	// Ignores ctx, the registry has to give up on it by itself
+	hang := CheckerFunc(func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	testCases := []struct {
+		name     string
+		register func(r *Registry)
+
+		wantStatus Status
+		wantChecks map[string]Status
+	}{
+		{
+			name:       "nothing registered",
+			register:   func(r *Registry) {},
+			wantStatus: StatusUp,
+			wantChecks: map[string]Status{},
+		},
+		{
+			name: "all up",
+			register: func(r *Registry) {
+				r.Register("mysql", up, time.Second, true)
+				r.Register("redis", up, time.Second, false)
+			},
+			wantStatus: StatusUp,
+			wantChecks: map[string]Status{"mysql": StatusUp, "redis": StatusUp},
+		},
+		{
+			name: "non-critical down",
+			register: func(r *Registry) {
+				r.Register("mysql", up, time.Second, true)
+				r.Register("sms", down, time.Second, false)
+			},
+			wantStatus: StatusDegraded,
+			wantChecks: map[string]Status{"mysql": StatusUp, "sms": StatusDown},
+		},
+		{
+			name: "critical down",
+			register: func(r *Registry) {
+				r.Register("mysql", down, time.Second, true)
+				r.Register("sms", down, time.Second, false)
+			},
+			wantStatus: StatusDown,
+			wantChecks: map[string]Status{"mysql": StatusDown, "sms": StatusDown},
+		},
+		{
+			name: "timeout",
+			register: func(r *Registry) {
+				r.Register("mysql", hang, 20*time.Millisecond, true)
+			},
+			wantStatus: StatusDown,
+			wantChecks: map[string]Status{"mysql": StatusDown},
+		},
+		{
+			name: "registered again",
+			register: func(r *Registry) {
+				r.Register("mysql", down, time.Second, true)
+				r.Register("mysql", up, time.Second, true)
+			},
+			wantStatus: StatusUp,
+			wantChecks: map[string]Status{"mysql": StatusUp},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewRegistry()
+			tc.register(r)
+
+			start := time.Now()
+			report := r.Check(context.Background())
+			assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+			assert.Equal(t, tc.wantStatus, report.Status)
+			checks := make(map[string]Status, len(report.Checks))
+			for name, res := range report.Checks {
+				checks[name] = res.Status
+				if res.Status == StatusDown {
+					assert.NotEmpty(t, res.Error)
+				}
+			}
+			assert.Equal(t, tc.wantChecks, checks)
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package logger
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type fieldsKey struct{}
+
+// WithFields returns a ctx carrying fields for WithContext, on top of
+// the ones ctx already carries. Used for request and user ids.
+func WithFields(ctx context.Context, fields ...Field) context.Context {
+	old, _ := ctx.Value(fieldsKey{}).([]Field)
+	merged := make([]Field, 0, len(old)+len(fields))
+	merged = append(merged, old...)
+	merged = append(merged, fields...)
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+// ContextFields returns the fields carried by ctx, followed by the ids of its span
+func ContextFields(ctx context.Context) []Field {
+	fields, _ := ctx.Value(fieldsKey{}).([]Field)
+	res := make([]Field, 0, len(fields)+2)
+	res = append(res, fields...)
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		res = append(res,
+			String("traceId", sc.TraceID().String()),
+			String("spanId", sc.SpanID().String()))
+	}
+	return res
+}
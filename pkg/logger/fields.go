@@ -1,6 +1,6 @@
 package logger
 
-var DEBUG = false
+import "time"
 
 func Error(err error) Field {
 	return Field{Key: "error", Val: err}
@@ -13,6 +13,7 @@ func String(key string, val string) Field {
 func Bool(key string, val bool) Field {
 	return Field{Key: key, Val: val}
 }
+
 func Int32(key string, val int32) Field {
 	return Field{Key: key, Val: val}
 }
@@ -24,3 +25,15 @@ func Int64(key string, val int64) Field {
 func Int(key string, val int) Field {
 	return Field{Key: key, Val: val}
 }
+
+func Strings(key string, val []string) Field {
+	return Field{Key: key, Val: val}
+}
+
+func Duration(key string, val time.Duration) Field {
+	return Field{Key: key, Val: val}
+}
+
+func Any(key string, val any) Field {
+	return Field{Key: key, Val: val}
+}
@@ -0,0 +1,26 @@
+package logger
+
+import "context"
+
+// NopLogger discards everything, for tests and optional loggers
+type NopLogger struct{}
+
+func NewNopLogger() NopLogger {
+	return NopLogger{}
+}
+
+func (NopLogger) Debug(msg string, args ...Field) {}
+
+func (NopLogger) Info(msg string, args ...Field) {}
+
+func (NopLogger) Warn(msg string, args ...Field) {}
+
+func (NopLogger) Error(msg string, args ...Field) {}
+
+func (n NopLogger) With(args ...Field) Logger {
+	return n
+}
+
+func (n NopLogger) WithContext(ctx context.Context) Logger {
+	return n
+}
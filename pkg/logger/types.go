@@ -1,10 +1,17 @@
 package logger
 
+import "context"
+
 type Logger interface {
 	Debug(msg string, args ...Field)
 	Info(msg string, args ...Field)
 	Warn(msg string, args ...Field)
 	Error(msg string, args ...Field)
+	// With returns a logger adding args to every entry
+	With(args ...Field) Logger
+	// WithContext returns a logger adding the fields carried by ctx,
+	// see WithFields, and the trace and span ids of its span
+	WithContext(ctx context.Context) Logger
 }
 
 type Field struct {
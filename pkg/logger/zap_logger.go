@@ -1,6 +1,10 @@
 package logger
 
-import "go.uber.org/zap"
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
 
 type ZapLogger struct {
 	l *zap.Logger
@@ -28,6 +32,17 @@ func (z *ZapLogger) Error(msg string, args ...Field) {
 	z.l.Error(msg, z.toArgs(args)...)
 }
 
+func (z *ZapLogger) With(args ...Field) Logger {
+	if len(args) == 0 {
+		return z
+	}
+	return &ZapLogger{l: z.l.With(z.toArgs(args)...)}
+}
+
+func (z *ZapLogger) WithContext(ctx context.Context) Logger {
+	return z.With(ContextFields(ctx)...)
+}
+
 func (z *ZapLogger) toArgs(args []Field) []zap.Field {
 	res := make([]zap.Field, 0, len(args))
 	for _, arg := range args {
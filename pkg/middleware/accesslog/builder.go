@@ -0,0 +1,147 @@
+package accesslog
+
+import (
+	"bytes"
+	"io"
+	"time"
+
+	"github.com/cyvqet/connectify/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Builder logs one entry per request through l.WithContext, so the entry
+// carries the request, user and trace ids. Bodies are off by default.
+type Builder struct {
+	l           logger.Logger
+	reqBody     bool
+	respBody    bool
+	maxBodySize int
+	masker      *masker
+	skipBodies  map[string]struct{}
+}
+
+func NewBuilder(l logger.Logger) *Builder {
+	return &Builder{
+		l:           l,
+		maxBodySize: 1024,
+		masker:      newMasker(nil),
+	}
+}
+
+// AllowReqBody logs request bodies
+func (b *Builder) AllowReqBody() *Builder {
+	b.reqBody = true
+	return b
+}
+
+// AllowRespBody logs response bodies
+func (b *Builder) AllowRespBody() *Builder {
+	b.respBody = true
+	return b
+}
+
+// MaxBodySize sets how many bytes of each body are logged, the rest is cut off
+func (b *Builder) MaxBodySize(n int) *Builder {
+	b.maxBodySize = n
+	return b
+}
+
+// MaskFields hides the values of these JSON fields and query parameters,
+// matched case-insensitively
+func (b *Builder) MaskFields(keys ...string) *Builder {
+	b.masker = newMasker(keys)
+	return b
+}
+
+// SkipBodies never logs the bodies of these routes, given as registered
+// with gin, e.g. /user/:id. Meant for credentials masking cannot be trusted with.
+func (b *Builder) SkipBodies(routes ...string) *Builder {
+	b.skipBodies = make(map[string]struct{}, len(routes))
+	for _, route := range routes {
+		b.skipBodies[route] = struct{}{}
+	}
+	return b
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		// Gin has matched the route before the first handler runs
+		_, skip := b.skipBodies[ctx.FullPath()]
+		fields := []logger.Field{
+			logger.String("method", ctx.Request.Method),
+			logger.String("path", ctx.Request.URL.Path),
+			logger.String("query", b.masker.maskQuery(ctx.Request.URL.RawQuery)),
+			logger.String("ip", ctx.ClientIP()),
+		}
+
+		if b.reqBody && !skip && ctx.Request.Body != nil {
+			fields = append(fields, logger.String("reqBody", b.peekBody(ctx)))
+		}
+
+		var resp *responseWriter
+		if b.respBody && !skip {
+			resp = &responseWriter{ResponseWriter: ctx.Writer, limit: b.maxBodySize}
+			ctx.Writer = resp
+		}
+
+		ctx.Next()
+
+		fields = append(fields,
+			logger.String("route", ctx.FullPath()),
+			logger.Int("status", ctx.Writer.Status()),
+			logger.Duration("duration", time.Since(start)),
+		)
+		if resp != nil {
+			fields = append(fields, logger.String("respBody", b.masker.maskJSON(resp.body.String())))
+		}
+		// The context is read after the handlers, they add the user id to it
+		b.l.WithContext(ctx.Request.Context()).Info("access", fields...)
+	}
+}
+
+// peekBody reads at most maxBodySize bytes and puts them back in front of the
+// unread rest, so large uploads are neither buffered nor lost
+func (b *Builder) peekBody(ctx *gin.Context) string {
+	buf := make([]byte, b.maxBodySize)
+	n, err := io.ReadFull(ctx.Request.Body, buf)
+	buf = buf[:n]
+	ctx.Request.Body = readCloser{
+		Reader: io.MultiReader(bytes.NewReader(buf), ctx.Request.Body),
+		Closer: ctx.Request.Body,
+	}
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		// The handler gets the same error when it reads on
+		return ""
+	}
+	return b.masker.maskJSON(string(buf))
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// responseWriter keeps a copy of the first limit bytes written
+type responseWriter struct {
+	gin.ResponseWriter
+	body  bytes.Buffer
+	limit int
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *responseWriter) capture(data []byte) {
+	if room := w.limit - w.body.Len(); room > 0 {
+		w.body.Write(data[:min(room, len(data))])
+	}
+}
@@ -0,0 +1,143 @@
+package accesslog
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cyvqet/connectify/pkg/logger"
+	"github.com/cyvqet/connectify/pkg/middleware/requestid"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordLogger keeps the fields of every entry, including the context ones
+type recordLogger struct {
+	logger.NopLogger
+	with    []logger.Field
+	entries *[]map[string]any
+}
+
+func (r recordLogger) Info(msg string, args ...logger.Field) {
+	entry := map[string]any{"msg": msg}
+	for _, f := range append(r.with, args...) {
+		entry[f.Key] = f.Val
+	}
+	*r.entries = append(*r.entries, entry)
+}
+
+func (r recordLogger) WithContext(ctx context.Context) logger.Logger {
+	r.with = append(r.with, logger.ContextFields(ctx)...)
+	return r
+}
+
+func TestBuilder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	testCases := []struct {
+		name    string
+		builder func(l logger.Logger) *Builder
+		body    string
+		query   string
+
+		wantReqBody  string
+		wantRespBody string
+		wantQuery    string
+	}{
+		{
+			name: "bodies off by default",
+			builder: func(l logger.Logger) *Builder {
+				return NewBuilder(l)
+			},
+			body: `{"phone":"13800000000"}`,
+		},
+		{
+			name: "sensitive fields masked",
+			builder: func(l logger.Logger) *Builder {
+				return NewBuilder(l).AllowReqBody().AllowRespBody().MaskFields("phone", "code", "password")
+			},
+			body:        `{"phone": "13800000000", "Code":"123456", "password":"a\"b", "channel":"sms"}`,
+			query:       "phone=13800000000&limit=10",
+			wantReqBody: `{"phone": "***", "Code":"***", "password":"***", "channel":"sms"}`,
+			// The numeric result code is not a verification code
+			wantRespBody: `{"code":0,"data":"123456","msg":"ok"}`,
+			wantQuery:    "limit=10&phone=%2A%2A%2A",
+		},
+		{
+			name: "cut off at the size limit, still masked",
+			builder: func(l logger.Logger) *Builder {
+				return NewBuilder(l).AllowReqBody().AllowRespBody().MaxBodySize(20).MaskFields("phone", "data")
+			},
+			body:         `{"channel":"sms","phone":"13800000000"}`,
+			wantReqBody:  `{"channel":"sms","ph`,
+			wantRespBody: `{"code":0,"data":"***"`,
+		},
+		{
+			name: "skipped route",
+			builder: func(l logger.Logger) *Builder {
+				return NewBuilder(l).AllowReqBody().AllowRespBody().SkipBodies("/user/:id")
+			},
+			body: `{"phone":"13800000000"}`,
+		},
+		{
+			name: "other routes still logged",
+			builder: func(l logger.Logger) *Builder {
+				return NewBuilder(l).AllowReqBody().AllowRespBody().SkipBodies("/user/login")
+			},
+			body:         `{"channel":"sms"}`,
+			wantReqBody:  `{"channel":"sms"}`,
+			wantRespBody: `{"code":0,"data":"123456","msg":"ok"}`,
+		},
+		{
+			name: "cut inside a masked value",
+			builder: func(l logger.Logger) *Builder {
+				return NewBuilder(l).AllowReqBody().MaxBodySize(14).MaskFields("phone")
+			},
+			body:        `{"phone":"13800000000"}`,
+			wantReqBody: `{"phone":"***"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var entries []map[string]any
+			server := gin.New()
+			server.Use(requestid.NewBuilder().Build(), tc.builder(recordLogger{entries: &entries}).Build())
+			server.POST("/user/:id", func(ctx *gin.Context) {
+				// The handler still gets the whole body
+				body, err := io.ReadAll(ctx.Request.Body)
+				require.NoError(t, err)
+				assert.Equal(t, tc.body, string(body))
+				ctx.JSON(http.StatusOK, gin.H{"code": 0, "msg": "ok", "data": "123456"})
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/user/1?"+tc.query, strings.NewReader(tc.body))
+			req.Header.Set("X-Request-Id", "req-1")
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			require.Len(t, entries, 1)
+			entry := entries[0]
+			assert.Equal(t, "access", entry["msg"])
+			assert.Equal(t, "req-1", entry["requestId"])
+			assert.Equal(t, "req-1", recorder.Header().Get("X-Request-Id"))
+			assert.Equal(t, "/user/:id", entry["route"])
+			assert.Equal(t, http.StatusOK, entry["status"])
+			assert.Equal(t, tc.wantQuery, entry["query"])
+			if tc.wantReqBody == "" {
+				assert.NotContains(t, entry, "reqBody")
+			} else {
+				assert.Equal(t, tc.wantReqBody, entry["reqBody"])
+			}
+			if tc.wantRespBody == "" {
+				assert.NotContains(t, entry, "respBody")
+			} else {
+				assert.Equal(t, tc.wantRespBody, entry["respBody"])
+			}
+		})
+	}
+}
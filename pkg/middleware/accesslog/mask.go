@@ -0,0 +1,61 @@
+package accesslog
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const masked = "***"
+
+// masker hides the values of sensitive keys in JSON bodies and query strings.
+// JSON is matched textually so bodies cut at the size limit are masked too.
+// Only string and array values are masked, so numeric fields such as the
+// result code of a response stay readable.
+type masker struct {
+	keys map[string]struct{}
+	json *regexp.Regexp
+}
+
+func newMasker(keys []string) *masker {
+	m := &masker{keys: make(map[string]struct{}, len(keys))}
+	if len(keys) == 0 {
+		return m
+	}
+	quoted := make([]string, 0, len(keys))
+	for _, key := range keys {
+		m.keys[strings.ToLower(key)] = struct{}{}
+		quoted = append(quoted, regexp.QuoteMeta(key))
+	}
+	// "key": "value" or "key": [...], the closing quote or bracket may be cut off
+	m.json = regexp.MustCompile(`(?i)("(?:` + strings.Join(quoted, "|") + `)"\s*:\s*)` +
+		`(?:"(?:[^"\\]|\\.)*"?|\[[^\]]*\]?)`)
+	return m
+}
+
+func (m *masker) maskJSON(body string) string {
+	if m.json == nil {
+		return body
+	}
+	return m.json.ReplaceAllString(body, `${1}"`+masked+`"`)
+}
+
+func (m *masker) maskQuery(rawQuery string) string {
+	if rawQuery == "" || len(m.keys) == 0 {
+		return rawQuery
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// Cannot tell which parts are sensitive
+		return masked
+	}
+	for key, vals := range values {
+		if _, ok := m.keys[strings.ToLower(key)]; !ok {
+			continue
+		}
+		for i := range vals {
+			vals[i] = masked
+		}
+	}
+	return values.Encode()
+}
@@ -0,0 +1,65 @@
+package prometheus
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/cyvqet/connectify/pkg/prometheusx"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// unmatchedRoute labels requests no route matched, so scanners probing
+// random paths cannot create new series
+const unmatchedRoute = "unmatched"
+
+// Builder records HTTP request latency and in-flight requests.
+// Requests are labeled by route template (/user/:id), never by raw path.
+type Builder struct {
+	Namespace string
+	Subsystem string
+
+	reg prometheus.Registerer
+}
+
+// NewBuilder registers the metrics with reg, middlewares built more than
+// once share them
+func NewBuilder(reg prometheus.Registerer, namespace, subsystem string) *Builder {
+	return &Builder{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		reg:       reg,
+	}
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	duration := prometheusx.MustRegister(b.reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: b.Namespace,
+		Subsystem: b.Subsystem,
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency, its count is the request count",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"}))
+	inFlight := prometheusx.MustRegister(b.reg, prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: b.Namespace,
+		Subsystem: b.Subsystem,
+		Name:      "requests_in_flight",
+		Help:      "HTTP requests being served",
+	}))
+
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		inFlight.Inc()
+		defer func() {
+			inFlight.Dec()
+			route := ctx.FullPath()
+			if route == "" {
+				route = unmatchedRoute
+			}
+			duration.WithLabelValues(ctx.Request.Method, route, strconv.Itoa(ctx.Writer.Status())).
+				Observe(time.Since(start).Seconds())
+		}()
+		ctx.Next()
+	}
+}
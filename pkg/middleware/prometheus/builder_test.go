@@ -0,0 +1,68 @@
+package prometheus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuilder_Build(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reg := prometheus.NewRegistry()
+	server := gin.New()
+	server.Use(NewBuilder(reg, "test", "http").Build())
+	server.GET("/user/:id", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	for _, path := range []string{"/user/1", "/user/2", "/no/such/path"} {
+		server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	counts := map[string]uint64{}
+	for _, family := range families {
+		if family.GetName() != "test_http_request_duration_seconds" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			counts[labels["method"]+" "+labels["route"]+" "+labels["status"]] = m.GetHistogram().GetSampleCount()
+		}
+	}
+
+	// Raw paths collapse into the route template
+	assert.Equal(t, map[string]uint64{
+		"GET /user/:id 200": 2,
+		"GET unmatched 404": 1,
+	}, counts)
+}
+
+func TestBuilder_BuildTwice(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	first := NewBuilder(reg, "test", "http").Build()
+	second := NewBuilder(reg, "test", "http").Build()
+
+	gin.SetMode(gin.TestMode)
+	for _, mdl := range []gin.HandlerFunc{first, second} {
+		server := gin.New()
+		server.Use(mdl)
+		server.GET("/ping", func(ctx *gin.Context) {
+			ctx.Status(http.StatusOK)
+		})
+		server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ping", nil))
+	}
+
+	// Both middlewares count into the same series
+	assert.Equal(t, 1, testutil.CollectAndCount(reg, "test_http_request_duration_seconds"))
+}
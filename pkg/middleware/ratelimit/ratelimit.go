@@ -2,26 +2,52 @@ package ratelimit
 
 import (
 	_ "embed"
+	"errors"
 	"fmt"
-	"log"
+	"math"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/cyvqet/connectify/pkg/logger"
 	"github.com/cyvqet/connectify/pkg/ratelimit"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Builder struct {
-	prefix  string
-	limiter ratelimit.Limiter
+	prefix   string
+	rules    atomic.Pointer[[]Rule]
+	keyFuncs map[string]KeyFunc
+	onReject func(ctx *gin.Context, status int)
+	l        logger.Logger
 }
 
-// NewBuilder creates a Builder instance
+// NewBuilder creates a Builder instance limiting every request by client ip
 func NewBuilder(limiter ratelimit.Limiter) *Builder {
-	return &Builder{
-		limiter: limiter,
-		prefix:  "ip-limiter", // Default prefix
+	return NewRuleBuilder([]Rule{
+		{Name: "ip", Path: "*", Key: KeyIP, Limiter: limiter},
+	})
+}
+
+// NewRuleBuilder creates a Builder where every rule matching a request applies
+func NewRuleBuilder(rules []Rule) *Builder {
+	b := &Builder{
+		prefix: "ip-limiter", // Default prefix
+		keyFuncs: map[string]KeyFunc{
+			KeyIP:    ipKey,
+			KeyPhone: phoneKey,
+		},
+		onReject: func(ctx *gin.Context, status int) {
+			ctx.AbortWithStatus(status)
+		},
+		l: logger.NewNopLogger(),
 	}
+	b.rules.Store(&rules)
+	return b
 }
 
 // Prefix sets the Redis key prefix
@@ -30,26 +56,147 @@ func (b *Builder) Prefix(prefix string) *Builder {
 	return b
 }
 
+// KeyFunc registers a rule key, e.g. the authenticated user id
+func (b *Builder) KeyFunc(name string, fn KeyFunc) *Builder {
+	b.keyFuncs[name] = fn
+	return b
+}
+
+// OnReject sets how a rejected request is answered, status is 429 when limited
+// or 500 when a limiter fails. fn must abort ctx. By default only the status is sent.
+func (b *Builder) OnReject(fn func(ctx *gin.Context, status int)) *Builder {
+	b.onReject = fn
+	return b
+}
+
+// Logger sets where limiter failures are logged, they are dropped by default
+func (b *Builder) Logger(l logger.Logger) *Builder {
+	b.l = l
+	return b
+}
+
+// SetRules replaces the rules of a running middleware at once, requests
+// already being checked finish with the old rules. Invalid rules are rejected
+// and the current ones kept.
+func (b *Builder) SetRules(rules []Rule) error {
+	if err := b.validate(rules); err != nil {
+		return err
+	}
+	b.rules.Store(&rules)
+	return nil
+}
+
+// Rules returns the rules currently in effect
+func (b *Builder) Rules() []Rule {
+	return slices.Clone(*b.rules.Load())
+}
+
 // Build creates a Gin rate limiting middleware
 func (b *Builder) Build() gin.HandlerFunc {
+	if err := b.validate(*b.rules.Load()); err != nil {
+		panic(err)
+	}
+
 	return func(ctx *gin.Context) {
-		limited, err := b.limit(ctx)
-		if err != nil {
-			log.Println(err)
-			// Conservative approach (rate limiting) vs aggressive approach (allowing through)
-			ctx.AbortWithStatus(http.StatusInternalServerError)
-			return
+		// The headers describe the most restrictive matching rule
+		var tightest *ratelimit.Result
+		for _, r := range *b.rules.Load() {
+			if !r.Match(ctx.Request.Method, ctx.Request.URL.Path) {
+				continue
+			}
+			res, err := b.limit(ctx, r)
+			if err != nil {
+				b.l.WithContext(ctx.Request.Context()).Error("rate limit check failed",
+					logger.String("rule", r.Name),
+					logger.Error(err))
+				// Conservative approach (rate limiting) vs aggressive approach (allowing through)
+				b.onReject(ctx, http.StatusInternalServerError)
+				return
+			}
+			if res.Limited {
+				b.setHeaders(ctx, res)
+				if res.Limit > 0 {
+					ctx.Header("Retry-After", strconv.Itoa(retryAfterSeconds(res.Reset)))
+				}
+				b.onReject(ctx, http.StatusTooManyRequests)
+				return
+			}
+			if res.Limit > 0 && (tightest == nil || res.Remaining < tightest.Remaining) {
+				tightest = &res
+			}
 		}
-		if limited {
-			ctx.AbortWithStatus(http.StatusTooManyRequests)
-			return
+		if tightest != nil {
+			b.setHeaders(ctx, *tightest)
 		}
 		ctx.Next()
 	}
 }
 
 // limit performs rate limiting checks
-func (b *Builder) limit(ctx *gin.Context) (bool, error) {
-	key := fmt.Sprintf("%s:%s", b.prefix, ctx.ClientIP())
-	return b.limiter.Limit(ctx, key)
+func (b *Builder) limit(ctx *gin.Context, r Rule) (ratelimit.Result, error) {
+	keyFunc, _ := b.keyFunc(r.Key)
+	kind := r.Key
+	value, ok := keyFunc(ctx)
+	if !ok {
+		kind, value = KeyIP, ctx.ClientIP()
+	}
+	// Redis limiters namespace the key by their algorithm
+	key := fmt.Sprintf("%s:%s:%s:%s", b.prefix, r.Name, kind, value)
+
+	if rl, ok := r.Limiter.(ratelimit.ResultLimiter); ok {
+		return rl.LimitResult(ctx, key)
+	}
+	limited, err := r.Limiter.Limit(ctx, key)
+	return ratelimit.Result{Limited: limited}, err
+}
+
+// setHeaders is a no-op for limiters that do not report a Result
+func (b *Builder) setHeaders(ctx *gin.Context, res ratelimit.Result) {
+	if res.Limit <= 0 {
+		return
+	}
+	ctx.Header("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	ctx.Header("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	ctx.Header("X-RateLimit-Reset", strconv.FormatInt(int64(math.Ceil(float64(res.Reset.UnixMilli())/1000)), 10))
+}
+
+// retryAfterSeconds rounds up, a client retrying too early is just limited again
+func retryAfterSeconds(reset time.Time) int {
+	return max(1, int(math.Ceil(time.Until(reset).Seconds())))
+}
+
+func (b *Builder) validate(rules []Rule) error {
+	var errs []error
+	names := make(map[string]struct{}, len(rules))
+	for _, r := range rules {
+		if r.Name == "" {
+			errs = append(errs, errors.New("rate limit rule without name"))
+			continue
+		}
+		if _, ok := names[r.Name]; ok {
+			errs = append(errs, fmt.Errorf("rate limit rule %q: duplicate name", r.Name))
+		}
+		names[r.Name] = struct{}{}
+		if r.Path == "" {
+			errs = append(errs, fmt.Errorf("rate limit rule %q: empty path", r.Name))
+		}
+		if r.Limiter == nil {
+			errs = append(errs, fmt.Errorf("rate limit rule %q: no limiter", r.Name))
+		}
+		if _, err := b.keyFunc(r.Key); err != nil {
+			errs = append(errs, fmt.Errorf("rate limit rule %q: %w", r.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (b *Builder) keyFunc(key string) (KeyFunc, error) {
+	if name, ok := strings.CutPrefix(key, headerKeyPrefix); ok && name != "" {
+		return headerKey(name), nil
+	}
+	fn, ok := b.keyFuncs[key]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", key)
+	}
+	return fn, nil
 }
@@ -0,0 +1,205 @@
+package ratelimit
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cyvqet/connectify/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilder_Rules(t *testing.T) {
+	testCases := []struct {
+		name string
+		// requests sent in order, with the status each should get
+		reqs     []func() *http.Request
+		wantCode []int
+	}{
+		{
+			name: "phone rule limits the same phone",
+			reqs: []func() *http.Request{
+				smsReq("13800000001"), smsReq("13800000001"),
+			},
+			wantCode: []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name: "phone rule counts phones separately",
+			reqs: []func() *http.Request{
+				smsReq("13800000001"), smsReq("13800000002"),
+			},
+			wantCode: []int{http.StatusOK, http.StatusOK},
+		},
+		{
+			name: "method does not match",
+			reqs: []func() *http.Request{
+				getReq("/user/send_sms_code"), getReq("/user/send_sms_code"),
+			},
+			wantCode: []int{http.StatusOK, http.StatusOK},
+		},
+		{
+			name: "prefix rule",
+			reqs: []func() *http.Request{
+				getReq("/user/profile"), getReq("/user/profile"), getReq("/user/profile"),
+			},
+			wantCode: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			server := gin.New()
+			server.Use(NewRuleBuilder([]Rule{
+				{
+					Name:    "user",
+					Path:    "/user/*",
+					Key:     KeyIP,
+					Limiter: ratelimit.NewLocalTokenBucketLimiter(time.Hour, 1, 2),
+				},
+				{
+					Name:    "sms",
+					Methods: []string{http.MethodPost},
+					Path:    "/user/send_sms_code",
+					Key:     KeyPhone,
+					Limiter: ratelimit.NewLocalTokenBucketLimiter(time.Hour, 1, 1),
+				},
+			}).Build())
+			echo := func(ctx *gin.Context) {
+				// The handler still sees the body read by the phone key
+				body, _ := io.ReadAll(ctx.Request.Body)
+				ctx.String(http.StatusOK, string(body))
+			}
+			server.POST("/user/send_sms_code", echo)
+			server.GET("/user/send_sms_code", echo)
+			server.GET("/user/profile", echo)
+
+			for i, reqBuilder := range tc.reqs {
+				req := reqBuilder()
+				rec := httptest.NewRecorder()
+				server.ServeHTTP(rec, req)
+				assert.Equal(t, tc.wantCode[i], rec.Code, "request %d", i+1)
+				if rec.Code == http.StatusOK && req.Method == http.MethodPost {
+					assert.Contains(t, rec.Body.String(), `"phone"`)
+				}
+			}
+		})
+	}
+}
+
+func smsReq(phone string) func() *http.Request {
+	return func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/user/send_sms_code",
+			bytes.NewReader([]byte(`{"phone":"`+phone+`"}`)))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+}
+
+func getReq(path string) func() *http.Request {
+	return func() *http.Request {
+		return httptest.NewRequest(http.MethodGet, path, nil)
+	}
+}
+
+func TestBuilder_Headers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	server.Use(NewRuleBuilder([]Rule{
+		{
+			Name:    "loose",
+			Path:    "*",
+			Key:     KeyIP,
+			Limiter: ratelimit.NewLocalTokenBucketLimiter(time.Hour, 10, 10),
+		},
+		{
+			Name:    "tight",
+			Path:    "/user/*",
+			Key:     KeyIP,
+			Limiter: ratelimit.NewLocalTokenBucketLimiter(time.Minute, 2, 2),
+		},
+	}).Build())
+	server.GET("/user/profile", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, getReq("/user/profile")())
+		return rec
+	}
+
+	rec := send()
+	assert.Equal(t, http.StatusOK, rec.Code)
+	// Headers come from the tightest rule
+	assert.Equal(t, "2", rec.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("X-RateLimit-Remaining"))
+	assert.Empty(t, rec.Header().Get("Retry-After"))
+
+	rec = send()
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+
+	rec = send()
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+	retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	assert.NoError(t, err)
+	// One token refills every 30s
+	assert.InDelta(t, 30, retryAfter, 1)
+	reset, err := strconv.ParseInt(rec.Header().Get("X-RateLimit-Reset"), 10, 64)
+	assert.NoError(t, err)
+	assert.Greater(t, reset, time.Now().Unix())
+}
+
+func TestBuilder_SetRules(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	b := NewRuleBuilder([]Rule{
+		{
+			Name:    "strict",
+			Path:    "*",
+			Key:     KeyIP,
+			Limiter: ratelimit.NewLocalTokenBucketLimiter(time.Hour, 1, 1),
+		},
+	})
+	server := gin.New()
+	server.Use(b.Build())
+	server.GET("/user/profile", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+	send := func() int {
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, getReq("/user/profile")())
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send())
+	assert.Equal(t, http.StatusTooManyRequests, send())
+
+	// Invalid rules are rejected and the running ones kept
+	err := b.SetRules([]Rule{
+		{Name: "bad", Path: "*", Key: "cookie"},
+		{Name: "bad", Path: "*", Key: KeyIP},
+	})
+	assert.Error(t, err)
+	assert.Equal(t, "strict", b.Rules()[0].Name)
+	assert.Equal(t, http.StatusTooManyRequests, send())
+
+	// Valid rules apply to the next request
+	err = b.SetRules([]Rule{
+		{
+			Name:    "loose",
+			Path:    "*",
+			Key:     KeyIP,
+			Limiter: ratelimit.NewLocalTokenBucketLimiter(time.Hour, 10, 10),
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, send())
+}
@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/cyvqet/connectify/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Built-in rule keys; "header:<Name>" keys by a request header
+const (
+	KeyIP    = "ip"
+	KeyPhone = "phone" // "phone" field of the JSON body
+)
+
+const (
+	headerKeyPrefix = "header:"
+	maxPhoneBody    = 4 << 10
+)
+
+// KeyFunc extracts the value a rule limits by; ok is false when the request
+// has no such value, the rule then limits by client ip instead
+type KeyFunc func(ctx *gin.Context) (value string, ok bool)
+
+// Rule limits the requests matching Methods and Path, counting them per Key
+type Rule struct {
+	Name string
+	// Empty matches every method
+	Methods []string
+	// Exact path, or a prefix when ending with "*", e.g. /user/* or * for every path
+	Path    string
+	Key     string
+	Limiter ratelimit.Limiter
+}
+
+func (r Rule) Match(method, path string) bool {
+	if len(r.Methods) > 0 && !slices.Contains(r.Methods, method) {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(r.Path, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+	return r.Path == path
+}
+
+func ipKey(ctx *gin.Context) (string, bool) {
+	return ctx.ClientIP(), true
+}
+
+// phoneKey reads the phone from the JSON body and puts the body back for the handler
+func phoneKey(ctx *gin.Context) (string, bool) {
+	if ctx.Request.Body == nil {
+		return "", false
+	}
+	body, err := io.ReadAll(io.LimitReader(ctx.Request.Body, maxPhoneBody))
+	if err != nil {
+		return "", false
+	}
+	ctx.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), ctx.Request.Body))
+
+	var req struct {
+		Phone string `json:"phone"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil || req.Phone == "" {
+		return "", false
+	}
+	return req.Phone, true
+}
+
+func headerKey(name string) KeyFunc {
+	return func(ctx *gin.Context) (string, bool) {
+		v := ctx.GetHeader(name)
+		return v, v != ""
+	}
+}
@@ -0,0 +1,62 @@
+package requestid
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/cyvqet/connectify/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxLen bounds ids taken from the client, longer ones are replaced
+const maxLen = 64
+
+// Builder gives every request an id, taken from the request header when the
+// caller sent one. The id is echoed in the response and put on the request
+// context for logger.WithContext.
+type Builder struct {
+	header string
+}
+
+func NewBuilder() *Builder {
+	return &Builder{header: "X-Request-Id"}
+}
+
+// Header sets the request and response header carrying the id
+func (b *Builder) Header(header string) *Builder {
+	b.header = header
+	return b
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetHeader(b.header)
+		if !valid(id) {
+			id = newId()
+		}
+		ctx.Header(b.header, id)
+		ctx.Request = ctx.Request.WithContext(
+			logger.WithFields(ctx.Request.Context(), logger.String("requestId", id)))
+		ctx.Next()
+	}
+}
+
+// valid only accepts short printable ids, they end up in every log line
+func valid(id string) bool {
+	if id == "" || len(id) > maxLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newId() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
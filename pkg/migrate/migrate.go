@@ -0,0 +1,391 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cyvqet/connectify/pkg/logger"
+)
+
+var (
+	// ErrSchemaBehind some migrations of this binary are not applied yet
+	ErrSchemaBehind = errors.New("schema is behind")
+	// ErrDirty a migration failed halfway, MySQL cannot roll DDL back.
+	// Fix the schema by hand and delete its row from schema_migrations.
+	ErrDirty = errors.New("schema is dirty")
+	// ErrLocked another instance kept the lock for longer than the lock timeout
+	ErrLocked = errors.New("another instance is migrating")
+)
+
+const table = "schema_migrations"
+
+var fileRe = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one schema change, Up applies it and Down reverts it
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Load reads the <version>_<name>.up.sql and .down.sql pairs in the root of
+// fsys, sorted by version
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		match := fileRe.FindStringSubmatch(e.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s: want <version>_<name>.up.sql or .down.sql", e.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", e.Name(), err)
+		}
+		data, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d: named both %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	res := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s: needs both up and down", m.Version, m.Name)
+		}
+		res = append(res, *m)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Version < res[j].Version
+	})
+	return res, nil
+}
+
+// Status is a migration and whether the database has it
+type Status struct {
+	Migration
+	Applied   bool
+	Dirty     bool
+	AppliedAt time.Time
+}
+
+// Migrator applies migrations to a MySQL database and records them in
+// schema_migrations. Up and Down hold a GET_LOCK named after the database,
+// so instances starting together migrate one at a time.
+type Migrator struct {
+	db          *sql.DB
+	migrations  []Migration
+	lockTimeout time.Duration
+	l           logger.Logger
+}
+
+func NewMigrator(db *sql.DB, migrations []Migration, lockTimeout time.Duration, l logger.Logger) *Migrator {
+	return &Migrator{
+		db:          db,
+		migrations:  migrations,
+		lockTimeout: lockTimeout,
+		l:           l,
+	}
+}
+
+// Up applies every migration the database does not have yet, in version
+// order, and returns how many it applied
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		rows, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := dirty(rows); err != nil {
+			return err
+		}
+		for _, mg := range m.migrations {
+			if _, ok := rows[mg.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, mg, true); err != nil {
+				return err
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the last steps migrations applied, newest first
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	reverted := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		rows, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := dirty(rows); err != nil {
+			return err
+		}
+		versions := make([]int64, 0, len(rows))
+		for v := range rows {
+			versions = append(versions, v)
+		}
+		sort.Slice(versions, func(i, j int) bool {
+			return versions[i] > versions[j]
+		})
+
+		for _, v := range versions {
+			if reverted == steps {
+				break
+			}
+			mg, ok := m.find(v)
+			if !ok {
+				return fmt.Errorf("migration %d is not in this binary, revert it with the one that applied it", v)
+			}
+			if err := m.apply(ctx, conn, mg, false); err != nil {
+				return err
+			}
+			reverted++
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every migration of this binary with its state in the database
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	rows, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Status, 0, len(m.migrations))
+	for _, mg := range m.migrations {
+		s := Status{Migration: mg}
+		if r, ok := rows[mg.Version]; ok {
+			s.Applied = true
+			s.Dirty = r.dirty
+			s.AppliedAt = time.UnixMilli(r.appliedAt)
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+// Check fails with ErrSchemaBehind when a migration of this binary is not
+// applied and with ErrDirty when one failed halfway. Migrations newer than
+// the binary are fine, the previous release keeps running during a rollout.
+func (m *Migrator) Check(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	var pending []string
+	for _, s := range statuses {
+		if s.Dirty {
+			return fmt.Errorf("%w: migration %d_%s", ErrDirty, s.Version, s.Name)
+		}
+		if !s.Applied {
+			pending = append(pending, fmt.Sprintf("%d_%s", s.Version, s.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: %s not applied", ErrSchemaBehind, strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// apply runs the up or down of mg on conn, marking it dirty until it succeeds
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mg Migration, up bool) error {
+	start := time.Now()
+	script, direction := mg.Up, "up"
+	if up {
+		_, err := conn.ExecContext(ctx, "INSERT INTO "+table+" (version, name, dirty, applied_at) VALUES (?, ?, TRUE, ?)",
+			mg.Version, mg.Name, start.UnixMilli())
+		if err != nil {
+			return err
+		}
+	} else {
+		script, direction = mg.Down, "down"
+		_, err := conn.ExecContext(ctx, "UPDATE "+table+" SET dirty = TRUE WHERE version = ?", mg.Version)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, stmt := range statements(script) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("migration %d_%s %s: %w", mg.Version, mg.Name, direction, err)
+		}
+	}
+
+	var err error
+	if up {
+		_, err = conn.ExecContext(ctx, "UPDATE "+table+" SET dirty = FALSE WHERE version = ?", mg.Version)
+	} else {
+		_, err = conn.ExecContext(ctx, "DELETE FROM "+table+" WHERE version = ?", mg.Version)
+	}
+	if err != nil {
+		return err
+	}
+	m.l.Info("migration "+direction,
+		logger.Int64("version", mg.Version),
+		logger.String("name", mg.Name),
+		logger.Duration("elapsed", time.Since(start)))
+	return nil
+}
+
+type appliedRow struct {
+	dirty     bool
+	appliedAt int64
+}
+
+// applied reads schema_migrations, empty while the table does not exist
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]appliedRow, error) {
+	var exists int
+	err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM information_schema.tables "+
+		"WHERE table_schema = DATABASE() AND table_name = ?", table).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int64]appliedRow)
+	if exists == 0 {
+		return res, nil
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version, dirty, applied_at FROM "+table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int64
+		var r appliedRow
+		if err := rows.Scan(&version, &r.dirty, &r.appliedAt); err != nil {
+			return nil, err
+		}
+		res[version] = r
+	}
+	return res, rows.Err()
+}
+
+// dirty fails while a migration is marked dirty, nothing is safe to run on top
+func dirty(rows map[int64]appliedRow) error {
+	for version, r := range rows {
+		if r.dirty {
+			return fmt.Errorf("%w: migration %d", ErrDirty, version)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) find(version int64) (Migration, bool) {
+	for _, mg := range m.migrations {
+		if mg.Version == version {
+			return mg, true
+		}
+	}
+	return Migration{}, false
+}
+
+// withLock runs fn on a connection holding the migration lock. The lock
+// belongs to the connection, it is released when the connection closes even
+// if RELEASE_LOCK does not get through.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var got sql.NullInt64
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(CONCAT(DATABASE(), '.', ?), ?)",
+		table, int(m.lockTimeout.Seconds())).Scan(&got)
+	if err != nil {
+		return err
+	}
+	if !got.Valid {
+		return errors.New("GET_LOCK failed")
+	}
+	if got.Int64 == 0 {
+		return ErrLocked
+	}
+	defer func() {
+		// The request context may be done, the lock is released regardless
+		_, _ = conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(CONCAT(DATABASE(), '.', ?))", table)
+	}()
+
+	_, err = conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+table+` (
+    version    BIGINT       NOT NULL,
+    name       VARCHAR(255) NOT NULL,
+    dirty      BOOLEAN      NOT NULL,
+    applied_at BIGINT       NOT NULL,
+    PRIMARY KEY (version)
+)`)
+	if err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// statements splits script at the semicolons ending a line, without the
+// lines holding nothing but a comment
+func statements(script string) []string {
+	var res []string
+	var b strings.Builder
+	flush := func() {
+		if stmt := strings.TrimSpace(b.String()); stmt != "" {
+			res = append(res, stmt)
+		}
+		b.Reset()
+	}
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		if strings.HasSuffix(trimmed, ";") {
+			b.WriteString(strings.TrimSuffix(strings.TrimRight(line, " \t\r"), ";"))
+			flush()
+			continue
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	flush()
+	return res
+}
@@ -0,0 +1,110 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	file := func(data string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(data)}
+	}
+	testCases := []struct {
+		name    string
+		fsys    fstest.MapFS
+		want    []Migration
+		wantErr string
+	}{
+		{
+			name: "sorted by version",
+			fsys: fstest.MapFS{
+				"0010_add_locale.up.sql":     file("ALTER TABLE users ADD locale VARCHAR(16);"),
+				"0010_add_locale.down.sql":   file("ALTER TABLE users DROP locale;"),
+				"0002_create_users.up.sql":   file("CREATE TABLE users (id BIGINT);"),
+				"0002_create_users.down.sql": file("DROP TABLE users;"),
+			},
+			want: []Migration{
+				{Version: 2, Name: "create_users", Up: "CREATE TABLE users (id BIGINT);", Down: "DROP TABLE users;"},
+				{Version: 10, Name: "add_locale", Up: "ALTER TABLE users ADD locale VARCHAR(16);", Down: "ALTER TABLE users DROP locale;"},
+			},
+		},
+		{
+			name: "missing down",
+			fsys: fstest.MapFS{
+				"0001_create_users.up.sql": file("CREATE TABLE users (id BIGINT);"),
+			},
+			wantErr: "migration 1_create_users: needs both up and down",
+		},
+		{
+			name: "version named twice",
+			fsys: fstest.MapFS{
+				"0001_create_users.up.sql":    file("CREATE TABLE users (id BIGINT);"),
+				"0001_create_people.down.sql": file("DROP TABLE users;"),
+			},
+			wantErr: "migration 1: named both",
+		},
+		{
+			name: "bad file name",
+			fsys: fstest.MapFS{
+				"create_users.sql": file("CREATE TABLE users (id BIGINT);"),
+			},
+			wantErr: "migration create_users.sql: want <version>_<name>.up.sql or .down.sql",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Load(tc.fsys)
+			if tc.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestStatements(t *testing.T) {
+	testCases := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name: "split at line ends",
+			script: `-- users
+CREATE TABLE users (
+    id BIGINT
+);
+CREATE INDEX idx_id ON users (id);
+`,
+			want: []string{
+				"CREATE TABLE users (\n    id BIGINT\n)",
+				"CREATE INDEX idx_id ON users (id)",
+			},
+		},
+		{
+			name:   "semicolon inside a line",
+			script: "INSERT INTO notes (body) VALUES ('a;b');",
+			want:   []string{"INSERT INTO notes (body) VALUES ('a;b')"},
+		},
+		{
+			name:   "last statement without semicolon",
+			script: "DROP TABLE users",
+			want:   []string{"DROP TABLE users"},
+		},
+		{
+			name:   "comments only",
+			script: "-- nothing yet\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, statements(tc.script))
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package migrate
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/cyvqet/connectify/pkg/logger"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testMigrations = []Migration{
+	{Version: 1, Name: "create_users", Up: "CREATE TABLE users (id BIGINT);", Down: "DROP TABLE users;"},
+	{Version: 2, Name: "add_locale",
+		Up:   "ALTER TABLE users ADD locale VARCHAR(16);\nCREATE INDEX idx_locale ON users (locale);",
+		Down: "ALTER TABLE users DROP locale;"},
+}
+
+// appliedVersion is a row of schema_migrations
+type appliedVersion struct {
+	version int64
+	dirty   bool
+}
+
+func expectLock(mock sqlmock.Sqlmock, got int64) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT GET_LOCK(CONCAT(DATABASE(), '.', ?), ?)")).
+		WithArgs(table, 60).
+		WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(got))
+}
+
+func expectCreateTable(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS " + table)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func expectRelease(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta("SELECT RELEASE_LOCK(CONCAT(DATABASE(), '.', ?))")).
+		WithArgs(table).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+// expectApplied answers the reads of schema_migrations, nil rows for a
+// database without the table
+func expectApplied(mock sqlmock.Sqlmock, applied []appliedVersion) {
+	exists := 0
+	if applied != nil {
+		exists = 1
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM information_schema.tables")).
+		WithArgs(table).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(exists))
+	if applied == nil {
+		return
+	}
+	rows := sqlmock.NewRows([]string{"version", "dirty", "applied_at"})
+	for _, a := range applied {
+		rows.AddRow(a.version, a.dirty, time.Now().UnixMilli())
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version, dirty, applied_at FROM " + table)).WillReturnRows(rows)
+}
+
+func expectExec(mock sqlmock.Sqlmock, query string, args ...driver.Value) *sqlmock.ExpectedExec {
+	e := mock.ExpectExec(regexp.QuoteMeta(query))
+	if len(args) > 0 {
+		e.WithArgs(args...)
+	}
+	return e.WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func newTestMigrator(t *testing.T) (*Migrator, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return NewMigrator(db, testMigrations, time.Minute, logger.NewNopLogger()), mock
+}
+
+func TestMigrator_Up(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(mock sqlmock.Sqlmock)
+
+		wantApplied int
+		wantErr     error
+		wantErrMsg  string
+	}{
+		{
+			name: "pending ones in order",
+			mock: func(mock sqlmock.Sqlmock) {
+				expectLock(mock, 1)
+				expectCreateTable(mock)
+				expectApplied(mock, []appliedVersion{{version: 1}})
+				expectExec(mock, "INSERT INTO schema_migrations (version, name, dirty, applied_at) VALUES (?, ?, TRUE, ?)",
+					int64(2), "add_locale", sqlmock.AnyArg())
+				expectExec(mock, "ALTER TABLE users ADD locale VARCHAR(16)")
+				expectExec(mock, "CREATE INDEX idx_locale ON users (locale)")
+				expectExec(mock, "UPDATE schema_migrations SET dirty = FALSE WHERE version = ?", int64(2))
+				expectRelease(mock)
+			},
+			wantApplied: 1,
+		},
+		{
+			name: "fresh database",
+			mock: func(mock sqlmock.Sqlmock) {
+				expectLock(mock, 1)
+				expectCreateTable(mock)
+				expectApplied(mock, nil)
+				for _, mg := range testMigrations {
+					expectExec(mock, "INSERT INTO schema_migrations", mg.Version, mg.Name, sqlmock.AnyArg())
+					for _, stmt := range statements(mg.Up) {
+						expectExec(mock, stmt)
+					}
+					expectExec(mock, "UPDATE schema_migrations SET dirty = FALSE WHERE version = ?", mg.Version)
+				}
+				expectRelease(mock)
+			},
+			wantApplied: 2,
+		},
+		{
+			// MySQL cannot roll the first statement back, the row stays dirty
+			name: "partial failure leaves the migration dirty",
+			mock: func(mock sqlmock.Sqlmock) {
+				expectLock(mock, 1)
+				expectCreateTable(mock)
+				expectApplied(mock, []appliedVersion{{version: 1}})
+				expectExec(mock, "INSERT INTO schema_migrations (version, name, dirty, applied_at) VALUES (?, ?, TRUE, ?)",
+					int64(2), "add_locale", sqlmock.AnyArg())
+				expectExec(mock, "ALTER TABLE users ADD locale VARCHAR(16)")
+				mock.ExpectExec(regexp.QuoteMeta("CREATE INDEX idx_locale ON users (locale)")).
+					WillReturnError(errors.New("duplicate key name"))
+				expectRelease(mock)
+			},
+			wantErrMsg: "migration 2_add_locale up: duplicate key name",
+		},
+		{
+			name: "dirty schema",
+			mock: func(mock sqlmock.Sqlmock) {
+				expectLock(mock, 1)
+				expectCreateTable(mock)
+				expectApplied(mock, []appliedVersion{{version: 1}, {version: 2, dirty: true}})
+				expectRelease(mock)
+			},
+			wantErr: ErrDirty,
+		},
+		{
+			name: "lock timeout",
+			mock: func(mock sqlmock.Sqlmock) {
+				expectLock(mock, 0)
+			},
+			wantErr: ErrLocked,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, mock := newTestMigrator(t)
+			tc.mock(mock)
+
+			applied, err := m.Up(context.Background())
+			switch {
+			case tc.wantErr != nil:
+				assert.ErrorIs(t, err, tc.wantErr)
+			case tc.wantErrMsg != "":
+				assert.EqualError(t, err, tc.wantErrMsg)
+			default:
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.wantApplied, applied)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMigrator_Down(t *testing.T) {
+	testCases := []struct {
+		name  string
+		steps int
+		mock  func(mock sqlmock.Sqlmock)
+
+		wantReverted int
+		wantErrMsg   string
+	}{
+		{
+			name:  "newest first",
+			steps: 1,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectLock(mock, 1)
+				expectCreateTable(mock)
+				expectApplied(mock, []appliedVersion{{version: 1}, {version: 2}})
+				expectExec(mock, "UPDATE schema_migrations SET dirty = TRUE WHERE version = ?", int64(2))
+				expectExec(mock, "ALTER TABLE users DROP locale")
+				expectExec(mock, "DELETE FROM schema_migrations WHERE version = ?", int64(2))
+				expectRelease(mock)
+			},
+			wantReverted: 1,
+		},
+		{
+			name:  "partial failure leaves the migration dirty",
+			steps: 1,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectLock(mock, 1)
+				expectCreateTable(mock)
+				expectApplied(mock, []appliedVersion{{version: 1}, {version: 2}})
+				expectExec(mock, "UPDATE schema_migrations SET dirty = TRUE WHERE version = ?", int64(2))
+				mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE users DROP locale")).
+					WillReturnError(errors.New("can't drop locale"))
+				expectRelease(mock)
+			},
+			wantErrMsg: "migration 2_add_locale down: can't drop locale",
+		},
+		{
+			name:  "applied by a newer binary",
+			steps: 1,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectLock(mock, 1)
+				expectCreateTable(mock)
+				expectApplied(mock, []appliedVersion{{version: 1}, {version: 2}, {version: 3}})
+				expectRelease(mock)
+			},
+			wantErrMsg: "migration 3 is not in this binary, revert it with the one that applied it",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, mock := newTestMigrator(t)
+			tc.mock(mock)
+
+			reverted, err := m.Down(context.Background(), tc.steps)
+			if tc.wantErrMsg != "" {
+				assert.EqualError(t, err, tc.wantErrMsg)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.wantReverted, reverted)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMigrator_Check(t *testing.T) {
+	testCases := []struct {
+		name    string
+		applied []appliedVersion
+
+		wantErr    error
+		wantErrMsg string
+	}{
+		{
+			name:    "up to date",
+			applied: []appliedVersion{{version: 1}, {version: 2}},
+		},
+		{
+			// The previous release keeps running during a rollout
+			name:    "newer than the binary",
+			applied: []appliedVersion{{version: 1}, {version: 2}, {version: 3}},
+		},
+		{
+			name:       "pending migration",
+			applied:    []appliedVersion{{version: 1}},
+			wantErr:    ErrSchemaBehind,
+			wantErrMsg: "schema is behind: 2_add_locale not applied",
+		},
+		{
+			name:       "fresh database",
+			wantErr:    ErrSchemaBehind,
+			wantErrMsg: "schema is behind: 1_create_users, 2_add_locale not applied",
+		},
+		{
+			name:       "dirty migration",
+			applied:    []appliedVersion{{version: 1}, {version: 2, dirty: true}},
+			wantErr:    ErrDirty,
+			wantErrMsg: "schema is dirty: migration 2_add_locale",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, mock := newTestMigrator(t)
+			expectApplied(mock, tc.applied)
+
+			err := m.Check(context.Background())
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				assert.EqualError(t, err, tc.wantErrMsg)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package prometheusx
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Register registers c with reg. If an identical collector is registered
+// already, e.g. by a second call of the constructor creating c, that one is
+// returned instead, so both callers update the same series.
+func Register[T prometheus.Collector](reg prometheus.Registerer, c T) (T, error) {
+	err := reg.Register(c)
+	if err == nil {
+		return c, nil
+	}
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		if existing, ok := are.ExistingCollector.(T); ok {
+			return existing, nil
+		}
+	}
+	return c, err
+}
+
+// MustRegister is Register panicking on error, for collectors created at startup
+func MustRegister[T prometheus.Collector](reg prometheus.Registerer, c T) T {
+	res, err := Register(reg, c)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
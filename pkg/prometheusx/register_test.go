@@ -0,0 +1,38 @@
+package prometheusx
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCounter(help string) *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "test_total",
+		Help: help,
+	}, []string{"result"})
+}
+
+func TestRegister(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	first, err := Register(reg, newCounter("test counter"))
+	require.NoError(t, err)
+	second, err := Register(reg, newCounter("test counter"))
+	require.NoError(t, err)
+
+	// The second registration shares the series of the first
+	second.WithLabelValues("ok").Inc()
+	assert.Same(t, first, second)
+	assert.Equal(t, 1.0, testutil.ToFloat64(first.WithLabelValues("ok")))
+
+	// Same name with another help is a conflict, not a duplicate
+	_, err = Register(reg, newCounter("another help"))
+	assert.Error(t, err)
+	assert.Panics(t, func() {
+		MustRegister(reg, newCounter("another help"))
+	})
+}
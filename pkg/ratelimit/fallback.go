@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/cyvqet/connectify/pkg/logger"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// FallbackMode decides what happens to a request when the primary limiter errors
+type FallbackMode string
+
+const (
+	FallbackLocal  FallbackMode = "local"  // use the in-process limiter instead
+	FallbackOpen   FallbackMode = "open"   // let the request through
+	FallbackClosed FallbackMode = "closed" // reject the request
+)
+
+func ParseFallbackMode(s string) (FallbackMode, error) {
+	switch mode := FallbackMode(s); mode {
+	case FallbackLocal, FallbackOpen, FallbackClosed:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown rate limit fallback mode %q", s)
+	}
+}
+
+// FallbackLimiter keeps rate limiting available when the primary (Redis) limiter
+// errors, so a Redis blip does not take the whole API down
+type FallbackLimiter struct {
+	primary   Limiter
+	local     Limiter // only used in FallbackLocal mode
+	mode      FallbackMode
+	fallbacks prometheus.Counter
+	l         logger.Logger
+	degraded  atomic.Bool
+}
+
+// NewFallbackLimiter counts the requests decided by the fallback in fallbacks,
+// give every limiter its own series, e.g. labelled by rule
+func NewFallbackLimiter(primary Limiter, local Limiter, mode FallbackMode, fallbacks prometheus.Counter,
+	l logger.Logger) *FallbackLimiter {
+	return &FallbackLimiter{
+		primary:   primary,
+		local:     local,
+		mode:      mode,
+		fallbacks: fallbacks,
+		l:         l,
+	}
+}
+
+func (f *FallbackLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	res, err := f.LimitResult(ctx, key)
+	return res.Limited, err
+}
+
+// LimitResult reports Limit 0 when the limiter deciding the request
+// does not implement ResultLimiter
+func (f *FallbackLimiter) LimitResult(ctx context.Context, key string) (Result, error) {
+	res, err := limitResult(ctx, f.primary, key)
+	if err == nil {
+		if f.degraded.CompareAndSwap(true, false) {
+			f.l.Info("rate limiter recovered", logger.String("mode", string(f.mode)))
+		}
+		return res, nil
+	}
+
+	f.fallbacks.Inc()
+	// Only log on the transition, a Redis outage would otherwise log every request
+	if f.degraded.CompareAndSwap(false, true) {
+		f.l.Warn("rate limiter failed, falling back",
+			logger.String("mode", string(f.mode)),
+			logger.Error(err))
+	}
+
+	switch f.mode {
+	case FallbackOpen:
+		return Result{Limited: false}, nil
+	case FallbackClosed:
+		return Result{Limited: true}, nil
+	default:
+		return limitResult(ctx, f.local, key)
+	}
+}
+
+func limitResult(ctx context.Context, l Limiter, key string) (Result, error) {
+	if rl, ok := l.(ResultLimiter); ok {
+		return rl.LimitResult(ctx, key)
+	}
+	limited, err := l.Limit(ctx, key)
+	return Result{Limited: limited}, err
+}
@@ -0,0 +1,30 @@
+---@diagnostic disable: undefined-global
+
+-- Rate limiting object
+local key = KEYS[1]
+-- Window size (milliseconds)
+local window = tonumber(ARGV[1])
+-- Threshold
+local threshold = tonumber(ARGV[2])
+-- Current time (milliseconds)
+local now = tonumber(ARGV[3])
+
+-- 1. One counter per window, the window index is part of the key
+local windowKey = key .. ":" .. math.floor(now / window)
+
+-- 2. Current window request count
+local cnt = tonumber(redis.call('GET', windowKey) or "0")
+
+-- 3. If the threshold is reached, execute rate limiting
+--    Returns {limited (1 or 0), count in window}
+if cnt >= threshold then
+    return {1, cnt}
+end
+
+-- 4. Allow through, count the request; the counter dies with its window
+redis.call('INCR', windowKey)
+if cnt == 0 then
+    redis.call('PEXPIRE', windowKey, window)
+end
+
+return {0, cnt + 1}
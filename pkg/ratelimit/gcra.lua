@@ -0,0 +1,28 @@
+---@diagnostic disable: undefined-global
+
+-- Rate limiting object
+local key = KEYS[1]
+-- Emission interval: time between two requests at the sustained rate (milliseconds)
+local emission = tonumber(ARGV[1])
+-- Burst tolerance: how far ahead of schedule a request may arrive (milliseconds)
+local tolerance = tonumber(ARGV[2])
+-- Current time (milliseconds)
+local now = tonumber(ARGV[3])
+
+-- 1. Theoretical arrival time of the next request, never in the past
+local tat = tonumber(redis.call('GET', key) or now)
+if tat < now then
+    tat = now
+end
+
+-- 2. Too far ahead of schedule, execute rate limiting
+if tat - tolerance > now then
+    return "true"
+end
+
+-- 3. Allow through, push the schedule back by one emission interval.
+--    The key is only needed until the schedule catches up with real time.
+local newTat = tat + emission
+redis.call('SET', key, newTat, 'PX', math.ceil(newTat - now))
+
+return "false"
@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// BenchmarkRedisLimiters compares the Redis limiters under the same rate.
+// Runs against an in-process Redis by default; set BENCH_REDIS_ADDR to use a
+// real server, which also reports memory per key via MEMORY USAGE.
+//
+//	go test -bench=RedisLimiters -benchmem ./pkg/ratelimit/
+func BenchmarkRedisLimiters(b *testing.B) {
+	const (
+		interval = time.Minute
+		rate     = 1000
+		keys     = 100
+	)
+	limiters := []struct {
+		name string
+		new  func(cmd redis.Cmdable) Limiter
+	}{
+		{"slide_window", func(cmd redis.Cmdable) Limiter {
+			return NewRedisSlideWindowLimiter(cmd, interval, rate)
+		}},
+		{"fixed_window", func(cmd redis.Cmdable) Limiter {
+			return NewRedisFixedWindowLimiter(cmd, interval, rate)
+		}},
+		{"token_bucket", func(cmd redis.Cmdable) Limiter {
+			return NewRedisTokenBucketLimiter(cmd, interval, rate, rate)
+		}},
+		{"gcra", func(cmd redis.Cmdable) Limiter {
+			return NewRedisGCRALimiter(cmd, interval, rate, rate)
+		}},
+	}
+
+	for _, lc := range limiters {
+		b.Run(lc.name, func(b *testing.B) {
+			cmd, remote := benchRedis(b)
+			l := lc.new(cmd)
+			ctx := context.Background()
+			prefix := fmt.Sprintf("bench:%s:%d", lc.name, time.Now().UnixNano())
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := l.Limit(ctx, fmt.Sprintf("%s:%d", prefix, i%keys)); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+
+			if remote {
+				// The limiters namespace their keys by algorithm, the names above match
+				b.ReportMetric(benchMemoryPerKey(b, cmd, algorithmKey(lc.name, prefix)+":*"), "redis-bytes/key")
+			}
+		})
+	}
+}
+
+func benchRedis(b *testing.B) (redis.Cmdable, bool) {
+	if addr := os.Getenv("BENCH_REDIS_ADDR"); addr != "" {
+		return redis.NewClient(&redis.Options{Addr: addr}), true
+	}
+	mr := miniredis.RunT(b)
+	return redis.NewClient(&redis.Options{Addr: mr.Addr()}), false
+}
+
+func benchMemoryPerKey(b *testing.B, cmd redis.Cmdable, pattern string) float64 {
+	ctx := context.Background()
+	keys, err := cmd.Keys(ctx, pattern).Result()
+	if err != nil {
+		b.Fatal(err)
+	}
+	if len(keys) == 0 {
+		b.Fatalf("no keys match %s", pattern)
+	}
+	var total int64
+	for _, key := range keys {
+		n, err := cmd.MemoryUsage(ctx, key).Result()
+		if err != nil {
+			b.Fatal(err)
+		}
+		total += n
+	}
+	cmd.Del(ctx, keys...)
+	return float64(total) / float64(len(keys))
+}
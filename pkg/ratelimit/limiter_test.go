@@ -0,0 +1,180 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cyvqet/connectify/pkg/logger"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRedisLimiters(t *testing.T) {
+	testCases := []struct {
+		name    string
+		limiter func(cmd redis.Cmdable) Limiter
+		// requests allowed back to back before the first rejection
+		wantAllowed int
+	}{
+		{
+			name: "slide window",
+			limiter: func(cmd redis.Cmdable) Limiter {
+				return NewRedisSlideWindowLimiter(cmd, time.Minute, 5)
+			},
+			wantAllowed: 5,
+		},
+		{
+			name: "fixed window",
+			limiter: func(cmd redis.Cmdable) Limiter {
+				return NewRedisFixedWindowLimiter(cmd, time.Hour, 5)
+			},
+			wantAllowed: 5,
+		},
+		{
+			name: "token bucket",
+			limiter: func(cmd redis.Cmdable) Limiter {
+				return NewRedisTokenBucketLimiter(cmd, time.Hour, 1, 5)
+			},
+			wantAllowed: 5,
+		},
+		{
+			name: "local token bucket",
+			limiter: func(cmd redis.Cmdable) Limiter {
+				return NewLocalTokenBucketLimiter(time.Hour, 1, 5)
+			},
+			wantAllowed: 5,
+		},
+		{
+			name: "gcra",
+			limiter: func(cmd redis.Cmdable) Limiter {
+				return NewRedisGCRALimiter(cmd, time.Hour, 1, 5)
+			},
+			wantAllowed: 5,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			cmd := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			l := tc.limiter(cmd)
+			ctx := context.Background()
+
+			for i := 0; i < tc.wantAllowed; i++ {
+				limited, err := l.Limit(ctx, "user:1")
+				require.NoError(t, err)
+				assert.False(t, limited, "request %d should be allowed", i+1)
+			}
+
+			limited, err := l.Limit(ctx, "user:1")
+			require.NoError(t, err)
+			assert.True(t, limited, "request over the limit should be limited")
+
+			// Keys are limited independently
+			limited, err = l.Limit(ctx, "user:2")
+			require.NoError(t, err)
+			assert.False(t, limited)
+		})
+	}
+}
+
+func TestRedisLimiters_SwitchAlgorithm(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cmd := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	// A hot reload changes the algorithm of a rule, the key stays the same
+	limiters := []Limiter{
+		NewRedisSlideWindowLimiter(cmd, time.Minute, 5),
+		NewRedisFixedWindowLimiter(cmd, time.Minute, 5),
+		NewRedisTokenBucketLimiter(cmd, time.Minute, 5, 5),
+		NewRedisGCRALimiter(cmd, time.Minute, 5, 5),
+	}
+	for _, l := range limiters {
+		limited, err := l.Limit(ctx, "ip-limiter:login:ip:127.0.0.1")
+		require.NoError(t, err)
+		assert.False(t, limited)
+	}
+}
+
+func TestRedisSlideWindowLimiter_LimitResult(t *testing.T) {
+	mr := miniredis.RunT(t)
+	l := NewRedisSlideWindowLimiter(redis.NewClient(&redis.Options{Addr: mr.Addr()}), time.Minute, 2).(ResultLimiter)
+	ctx := context.Background()
+	start := time.Now()
+
+	res, err := l.LimitResult(ctx, "ip:127.0.0.1")
+	require.NoError(t, err)
+	assert.False(t, res.Limited)
+	assert.Equal(t, 2, res.Limit)
+	assert.Equal(t, 1, res.Remaining)
+
+	_, err = l.LimitResult(ctx, "ip:127.0.0.1")
+	require.NoError(t, err)
+
+	res, err = l.LimitResult(ctx, "ip:127.0.0.1")
+	require.NoError(t, err)
+	assert.True(t, res.Limited)
+	assert.Equal(t, 0, res.Remaining)
+	// The next slot frees when the oldest request leaves the window
+	assert.WithinDuration(t, start.Add(time.Minute), res.Reset, time.Second)
+}
+
+func TestFallbackLimiter(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mode        FallbackMode
+		wantLimited []bool
+	}{
+		{
+			name:        "local",
+			mode:        FallbackLocal,
+			wantLimited: []bool{false, false, true},
+		},
+		{
+			name:        "open",
+			mode:        FallbackOpen,
+			wantLimited: []bool{false, false, false},
+		},
+		{
+			name:        "closed",
+			mode:        FallbackClosed,
+			wantLimited: []bool{true, true, true},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fallbacks := prometheus.NewCounter(prometheus.CounterOpts{Name: "fallbacks_total"})
+			l := NewFallbackLimiter(
+				brokenLimiter{},
+				NewLocalTokenBucketLimiter(time.Hour, 1, 2),
+				tc.mode,
+				fallbacks,
+				logger.NewZapLogger(zap.NewNop()),
+			)
+
+			for i, want := range tc.wantLimited {
+				limited, err := l.Limit(context.Background(), "ip:127.0.0.1")
+				require.NoError(t, err)
+				assert.Equal(t, want, limited, "request %d", i+1)
+			}
+			assert.Equal(t, float64(len(tc.wantLimited)), testutil.ToFloat64(fallbacks))
+		})
+	}
+}
+
+// brokenLimiter behaves like a Redis limiter while Redis is down
+type brokenLimiter struct{}
+
+func (brokenLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	return false, errors.New("redis: connection refused")
+}
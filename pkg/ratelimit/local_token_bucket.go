@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+const localShardCount = 64
+
+// LocalTokenBucketLimiter is an in-process token bucket limiter.
+// Keys are spread over shards so requests for different keys rarely contend
+// on the same lock, and buckets idle long enough to be full again are evicted.
+// Limits are per process: with N instances the cluster allows N times the rate.
+type LocalTokenBucketLimiter struct {
+	shards      [localShardCount]localShard
+	tokensPerNs float64       // refill speed
+	burst       float64       // bucket capacity
+	idle        time.Duration // a bucket unused this long is full again and can be dropped
+}
+
+type localShard struct {
+	mu        sync.Mutex
+	buckets   map[string]*localBucket
+	lastSweep time.Time
+}
+
+type localBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewLocalTokenBucketLimiter(interval time.Duration, rate int, burst int) Limiter {
+	l := &LocalTokenBucketLimiter{
+		tokensPerNs: float64(rate) / float64(interval.Nanoseconds()),
+		burst:       float64(burst),
+	}
+	l.idle = time.Duration(l.burst / l.tokensPerNs)
+	for i := range l.shards {
+		l.shards[i].buckets = make(map[string]*localBucket)
+	}
+	return l
+}
+
+func (l *LocalTokenBucketLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	res, err := l.LimitResult(ctx, key)
+	return res.Limited, err
+}
+
+func (l *LocalTokenBucketLimiter) LimitResult(ctx context.Context, key string) (Result, error) {
+	now := time.Now()
+	shard := &l.shards[l.shardIndex(key)]
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	// Sweep lazily, at most once per idle period per shard
+	if now.Sub(shard.lastSweep) > l.idle {
+		for k, b := range shard.buckets {
+			if now.Sub(b.last) > l.idle {
+				delete(shard.buckets, k)
+			}
+		}
+		shard.lastSweep = now
+	}
+
+	b, ok := shard.buckets[key]
+	if !ok {
+		b = &localBucket{tokens: l.burst, last: now}
+		shard.buckets[key] = b
+	}
+
+	b.tokens = min(l.burst, b.tokens+float64(now.Sub(b.last).Nanoseconds())*l.tokensPerNs)
+	b.last = now
+	limited := b.tokens < 1
+	if !limited {
+		b.tokens--
+	}
+
+	reset := now
+	if b.tokens < 1 {
+		// Time until the next whole token
+		reset = now.Add(time.Duration((1 - b.tokens) / l.tokensPerNs))
+	}
+	return Result{
+		Limited:   limited,
+		Limit:     int(l.burst),
+		Remaining: int(b.tokens),
+		Reset:     reset,
+	}, nil
+}
+
+func (l *LocalTokenBucketLimiter) shardIndex(key string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum32() % localShardCount
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//go:embed fixed_window.lua
+var luaFixedWindow string
+
+// RedisFixedWindowLimiter counts requests per aligned window with a single counter,
+// O(1) memory per key at the cost of allowing up to 2x rate across a window boundary
+type RedisFixedWindowLimiter struct {
+	cmd      redis.Cmdable // redis client
+	interval time.Duration // time window length
+	rate     int           // maximum number of requests allowed within the window
+}
+
+func NewRedisFixedWindowLimiter(cmd redis.Cmdable, interval time.Duration, rate int) Limiter {
+	return &RedisFixedWindowLimiter{
+		cmd:      cmd,
+		interval: interval,
+		rate:     rate,
+	}
+}
+
+func (l *RedisFixedWindowLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	res, err := l.LimitResult(ctx, key)
+	return res.Limited, err
+}
+
+func (l *RedisFixedWindowLimiter) LimitResult(ctx context.Context, key string) (Result, error) {
+	now := time.Now().UnixMilli()
+	vals, err := l.cmd.Eval(ctx, luaFixedWindow, []string{algorithmKey("fixed_window", key)},
+		l.interval.Milliseconds(), l.rate, now).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(vals) != 2 {
+		return Result{}, fmt.Errorf("unexpected fixed window result: %v", vals)
+	}
+
+	window := l.interval.Milliseconds()
+	return Result{
+		Limited:   vals[0] == 1,
+		Limit:     l.rate,
+		Remaining: max(0, l.rate-int(vals[1])),
+		Reset:     time.UnixMilli((now/window + 1) * window),
+	}, nil
+}
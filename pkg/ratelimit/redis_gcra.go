@@ -0,0 +1,38 @@
+package ratelimit
+
+import (
+	"context"
+	_ "embed"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//go:embed gcra.lua
+var luaGCRA string
+
+// RedisGCRALimiter implements the generic cell rate algorithm: it stores only
+// the theoretical arrival time of the next request, so it behaves like a token
+// bucket of burst tokens refilled at rate per interval with a single value per key
+type RedisGCRALimiter struct {
+	cmd      redis.Cmdable // redis client
+	interval time.Duration // time window length
+	rate     int           // sustained number of requests per interval
+	burst    int           // requests allowed back to back
+}
+
+func NewRedisGCRALimiter(cmd redis.Cmdable, interval time.Duration, rate int, burst int) Limiter {
+	return &RedisGCRALimiter{
+		cmd:      cmd,
+		interval: interval,
+		rate:     rate,
+		burst:    burst,
+	}
+}
+
+func (l *RedisGCRALimiter) Limit(ctx context.Context, key string) (bool, error) {
+	emission := float64(l.interval.Milliseconds()) / float64(l.rate)
+	tolerance := emission * float64(l.burst-1)
+	return l.cmd.Eval(ctx, luaGCRA, []string{algorithmKey("gcra", key)},
+		emission, tolerance, time.Now().UnixMilli()).Bool()
+}
@@ -3,6 +3,7 @@ package ratelimit
 import (
 	"context"
 	_ "embed"
+	"fmt"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -26,6 +27,25 @@ func NewRedisSlideWindowLimiter(cmd redis.Cmdable, interval time.Duration, rate
 }
 
 func (l *RedisSlideWindowLimiter) Limit(ctx context.Context, key string) (bool, error) {
-	return l.cmd.Eval(ctx, luaScript, []string{key},
-		l.interval.Milliseconds(), l.rate, time.Now().UnixMilli()).Bool()
+	res, err := l.LimitResult(ctx, key)
+	return res.Limited, err
+}
+
+func (l *RedisSlideWindowLimiter) LimitResult(ctx context.Context, key string) (Result, error) {
+	vals, err := l.cmd.Eval(ctx, luaScript, []string{algorithmKey("slide_window", key)},
+		l.interval.Milliseconds(), l.rate, time.Now().UnixMilli()).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(vals) != 3 {
+		return Result{}, fmt.Errorf("unexpected slide window result: %v", vals)
+	}
+
+	limited, cnt, oldest := vals[0] == 1, int(vals[1]), vals[2]
+	return Result{
+		Limited:   limited,
+		Limit:     l.rate,
+		Remaining: max(0, l.rate-cnt),
+		Reset:     time.UnixMilli(oldest).Add(l.interval),
+	}, nil
 }
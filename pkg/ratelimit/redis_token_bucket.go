@@ -0,0 +1,36 @@
+package ratelimit
+
+import (
+	"context"
+	_ "embed"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//go:embed token_bucket.lua
+var luaTokenBucket string
+
+// RedisTokenBucketLimiter refills rate tokens per interval into a bucket of burst tokens,
+// every request takes one token. O(1) memory per key.
+type RedisTokenBucketLimiter struct {
+	cmd      redis.Cmdable // redis client
+	interval time.Duration // refill period
+	rate     int           // tokens refilled per interval
+	burst    int           // bucket capacity
+}
+
+func NewRedisTokenBucketLimiter(cmd redis.Cmdable, interval time.Duration, rate int, burst int) Limiter {
+	return &RedisTokenBucketLimiter{
+		cmd:      cmd,
+		interval: interval,
+		rate:     rate,
+		burst:    burst,
+	}
+}
+
+func (l *RedisTokenBucketLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	tokensPerMs := float64(l.rate) / float64(l.interval.Milliseconds())
+	return l.cmd.Eval(ctx, luaTokenBucket, []string{algorithmKey("token_bucket", key)},
+		l.burst, tokensPerMs, time.Now().UnixMilli()).Bool()
+}
@@ -0,0 +1,35 @@
+package ratelimit
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// ReloadableLimiter delegates to a limiter that can be replaced while running,
+// e.g. when its thresholds change in the config file
+type ReloadableLimiter struct {
+	cur atomic.Pointer[limiterHolder]
+}
+
+type limiterHolder struct {
+	l Limiter
+}
+
+func NewReloadableLimiter(l Limiter) *ReloadableLimiter {
+	r := &ReloadableLimiter{}
+	r.Swap(l)
+	return r
+}
+
+// Swap makes l decide every request from now on
+func (r *ReloadableLimiter) Swap(l Limiter) {
+	r.cur.Store(&limiterHolder{l: l})
+}
+
+func (r *ReloadableLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	return r.cur.Load().l.Limit(ctx, key)
+}
+
+func (r *ReloadableLimiter) LimitResult(ctx context.Context, key string) (Result, error) {
+	return limitResult(ctx, r.cur.Load().l, key)
+}
@@ -12,6 +12,17 @@ local now = tonumber(ARGV[3])
 -- Window start time = (now - window, now]
 local min = now - window
 
+-- Returns {limited (1 or 0), count in window, score of the oldest entry in window}.
+-- The oldest entry leaving the window is when the next request is allowed.
+local function result(limited, cnt)
+    local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+    local oldestScore = now
+    if oldest[2] ~= nil then
+        oldestScore = tonumber(oldest[2])
+    end
+    return {limited, cnt, oldestScore}
+end
+
 -- 1. Delete request records before the window, using "(" to exclude the boundary equal to min
 redis.call('ZREMRANGEBYSCORE', key, '-inf', '(' .. min)
 
@@ -20,8 +31,7 @@ local cnt = redis.call('ZCARD', key)
 
 -- 3. If the threshold is reached, execute rate limiting
 if cnt >= threshold then
-    -- Return string "true" to indicate that the request is rate limited
-    return "true"
+    return result(1, cnt)
 end
 
 -- 4. Allow through, write current request record
@@ -37,5 +47,4 @@ redis.call('ZADD', key, now, member)
 redis.call('PEXPIRE', key, window)
 redis.call('PEXPIRE', seqKey, window)
 
--- Return string "false" to indicate that the request is not rate limited
-return "false"
+return result(0, cnt + 1)
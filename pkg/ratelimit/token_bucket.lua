@@ -0,0 +1,38 @@
+---@diagnostic disable: undefined-global
+
+-- Rate limiting object
+local key = KEYS[1]
+-- Bucket capacity (maximum burst)
+local capacity = tonumber(ARGV[1])
+-- Refill rate (tokens per millisecond)
+local rate = tonumber(ARGV[2])
+-- Current time (milliseconds)
+local now = tonumber(ARGV[3])
+
+-- 1. Load the bucket, a missing bucket is full
+local bucket = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil or ts == nil then
+    tokens = capacity
+    ts = now
+end
+
+-- 2. Refill the tokens accumulated since the last request
+local elapsed = math.max(0, now - ts)
+tokens = math.min(capacity, tokens + elapsed * rate)
+
+-- 3. Take one token if there is one
+local limited = tokens < 1
+if not limited then
+    tokens = tokens - 1
+end
+
+-- 4. Save the bucket, it can be dropped once it would be full again
+redis.call('HSET', key, 'tokens', tokens, 'ts', now)
+redis.call('PEXPIRE', key, math.ceil((capacity - tokens) / rate) + 1)
+
+if limited then
+    return "true"
+end
+return "false"
@@ -1,7 +1,31 @@
 package ratelimit
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Limiter interface {
 	Limit(ctx context.Context, key string) (bool, error) // return true if rate limited, false otherwise
 }
+
+// Result is the state of a key after a request was counted (or rejected)
+type Result struct {
+	Limited   bool
+	Limit     int       // requests allowed per window, 0 if unknown
+	Remaining int       // requests still allowed right now
+	Reset     time.Time // when the next request will be allowed again
+}
+
+// algorithmKey namespaces key by the algorithm limiting it. Each algorithm
+// keeps another Redis type under its key, switching the algorithm of a
+// running limiter would otherwise fail with WRONGTYPE until the key expires.
+func algorithmKey(algorithm, key string) string {
+	return algorithm + ":" + key
+}
+
+// ResultLimiter is implemented by limiters that can report more than limited or not
+type ResultLimiter interface {
+	Limiter
+	LimitResult(ctx context.Context, key string) (Result, error)
+}
@@ -0,0 +1,73 @@
+package redisx
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/cyvqet/connectify/pkg/prometheusx"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+// PrometheusHook times Redis commands by command name and result.
+// Command names are a small fixed set, keys are never used as labels.
+type PrometheusHook struct {
+	duration *prometheus.HistogramVec
+}
+
+// NewPrometheusHook registers the histogram with reg, hooks of several
+// clients share it
+func NewPrometheusHook(reg prometheus.Registerer, namespace, subsystem string) *PrometheusHook {
+	duration := prometheusx.MustRegister(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "command_duration_seconds",
+		Help:      "Redis command latency, a pipeline counts as one pipeline command",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5},
+	}, []string{"command", "result"}))
+	return &PrometheusHook{
+		duration: duration,
+	}
+}
+
+func (h *PrometheusHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		start := time.Now()
+		conn, err := next(ctx, network, addr)
+		h.observe("dial", err, start)
+		return conn, err
+	}
+}
+
+func (h *PrometheusHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		h.observe(cmd.Name(), err, start)
+		return err
+	}
+}
+
+func (h *PrometheusHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		h.observe("pipeline", err, start)
+		return err
+	}
+}
+
+func (h *PrometheusHook) observe(command string, err error, start time.Time) {
+	result := "ok"
+	switch {
+	case errors.Is(err, redis.Nil):
+		// A missing key is a normal answer, not a failure
+		result = "nil"
+	case err != nil:
+		result = "error"
+	}
+	h.duration.WithLabelValues(command, result).Observe(time.Since(start).Seconds())
+}
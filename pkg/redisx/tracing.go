@@ -0,0 +1,76 @@
+package redisx
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingHook creates a span per command. Keys and arguments are left out,
+// they contain phone numbers and verification codes.
+type TracingHook struct {
+	tracer trace.Tracer
+}
+
+func NewTracingHook(tp trace.TracerProvider) *TracingHook {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return &TracingHook{
+		tracer: tp.Tracer("github.com/cyvqet/connectify/pkg/redisx"),
+	}
+}
+
+func (h *TracingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		ctx, span := h.start(ctx, "dial")
+		defer span.End()
+		conn, err := next(ctx, network, addr)
+		h.end(span, err)
+		return conn, err
+	}
+}
+
+func (h *TracingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := h.start(ctx, cmd.Name())
+		defer span.End()
+		err := next(ctx, cmd)
+		h.end(span, err)
+		return err
+	}
+}
+
+func (h *TracingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := h.start(ctx, "pipeline")
+		defer span.End()
+		span.SetAttributes(attribute.Int("db.redis.num_cmd", len(cmds)))
+		err := next(ctx, cmds)
+		h.end(span, err)
+		return err
+	}
+}
+
+func (h *TracingHook) start(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return h.tracer.Start(ctx, "redis:"+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "redis"),
+			attribute.String("db.operation", operation),
+		))
+}
+
+func (h *TracingHook) end(span trace.Span, err error) {
+	// A missing key is a normal answer, not a failure
+	if err != nil && !errors.Is(err, redis.Nil) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
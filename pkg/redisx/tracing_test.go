@@ -0,0 +1,53 @@
+package redisx
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracingHook(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	client.AddHook(NewTracingHook(tp))
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	require.NoError(t, client.Set(ctx, "phone:13800000000", "123456", 0).Err())
+	assert.ErrorIs(t, client.Get(ctx, "missing").Err(), redis.Nil)
+	assert.Error(t, client.Do(ctx, "nosuchcommand").Err())
+	parent.End()
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	set := spans["redis:set"]
+	require.NotNil(t, set)
+	assert.Equal(t, parent.SpanContext().SpanID(), set.Parent().SpanID())
+	assert.Equal(t, codes.Unset, set.Status().Code)
+	for _, attr := range set.Attributes() {
+		// Keys and values must not leak into spans
+		assert.NotContains(t, attr.Value.Emit(), "13800000000")
+		assert.NotContains(t, attr.Value.Emit(), "123456")
+	}
+	assert.Contains(t, set.Attributes(), attribute.String("db.operation", "set"))
+
+	// A missing key is not an error
+	require.NotNil(t, spans["redis:get"])
+	assert.Equal(t, codes.Unset, spans["redis:get"].Status().Code)
+
+	require.NotNil(t, spans["redis:nosuchcommand"])
+	assert.Equal(t, codes.Error, spans["redis:nosuchcommand"].Status().Code)
+}
@@ -1,2 +1,3 @@
--- Auto-create database on MySQL container startup
+-- Auto-create database on MySQL container startup, the tables come from
+-- the migrations in internal/repository/dao/migrations
 CREATE DATABASE IF NOT EXISTS connectify;
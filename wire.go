@@ -9,36 +9,59 @@ import (
 	"github.com/cyvqet/connectify/internal/service"
 	"github.com/cyvqet/connectify/internal/web"
 	"github.com/cyvqet/connectify/ioc"
+	"github.com/cyvqet/connectify/pkg/migrate"
 
-	"github.com/gin-gonic/gin"
 	"github.com/google/wire"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func InitWebServer() *gin.Engine {
+func InitApp() *ioc.App {
 	wire.Build(
 		// Third-party dependencies
-		ioc.InitRedis, ioc.InitDB, ioc.InitLogger,
+		ioc.InitRedis, ioc.InitDB, ioc.InitLogger, ioc.InitLogLevel,
+		ioc.InitTracerProvider, ioc.InitPrometheusRegisterer,
+		wire.Bind(new(trace.TracerProvider), new(*sdktrace.TracerProvider)),
 
 		// DAO part
 		dao.NewUserDao,
+		dao.NewSMSLogDao,
 
 		// cache part
-		cache.NewCodeCache, cache.NewUserCache,
+		ioc.InitUserCache, ioc.InitCodeStore,
+		ioc.InitSMSQuotaCache,
+		ioc.InitRedisHealthChecker,
+		wire.Bind(new(cache.Health), new(*cache.RedisHealthChecker)),
 
 		// repository part
-		repository.NewUserRepository,
-		repository.NewCodeRepository,
+		ioc.InitUserRepository,
+		ioc.InitCodeRepository,
+		repository.NewSMSLogRepository,
+		repository.NewSMSQuotaRepository,
 
 		// Service part
 		ioc.InitSmsService,
+		ioc.InitVoiceService,
 		service.NewUserService,
+		ioc.InitCodePolicies,
 		service.NewCodeService,
+		service.NewSMSLogService,
 
 		// handler part
+		web.NewWrapper, ioc.InitJWTHandler,
 		web.NewUserHandler,
+		ioc.InitSMSHandler,
+		ioc.InitSMSLogHandler,
 
+		ioc.InitRateLimitRules,
 		ioc.InitGinMiddlewares,
 		ioc.InitWebServer,
+		ioc.InitApp,
 	)
-	return gin.Default()
+	return new(ioc.App)
+}
+
+func InitMigrator() (*migrate.Migrator, func(), error) {
+	wire.Build(ioc.InitLogger, ioc.InitLogLevel, ioc.InitMigrator)
+	return new(migrate.Migrator), nil, nil
 }
@@ -8,30 +8,58 @@ package main
 
 import (
 	"github.com/cyvqet/connectify/internal/repository"
-	"github.com/cyvqet/connectify/internal/repository/cache"
 	"github.com/cyvqet/connectify/internal/repository/dao"
 	"github.com/cyvqet/connectify/internal/service"
 	"github.com/cyvqet/connectify/internal/web"
 	"github.com/cyvqet/connectify/ioc"
-	"github.com/gin-gonic/gin"
+	"github.com/cyvqet/connectify/pkg/migrate"
 )
 
 // Injectors from wire.go:
 
-func InitWebServer() *gin.Engine {
-	cmdable := ioc.InitRedis()
-	v := ioc.InitGinMiddlewares(cmdable)
-	db := ioc.InitDB()
+func InitApp() *ioc.App {
+	atomicLevel := ioc.InitLogLevel()
+	logger := ioc.InitLogger(atomicLevel)
+	tracerProvider := ioc.InitTracerProvider(logger)
+	registerer := ioc.InitPrometheusRegisterer()
+	cmdable := ioc.InitRedis(tracerProvider, registerer)
+	rateLimitRules := ioc.InitRateLimitRules(cmdable, registerer, logger)
+	jwtHandler := ioc.InitJWTHandler()
+	v := ioc.InitGinMiddlewares(rateLimitRules, jwtHandler, tracerProvider, registerer, logger)
+	db := ioc.InitDB(tracerProvider, registerer, logger)
 	userDao := dao.NewUserDao(db)
-	userCache := cache.NewUserCache(cmdable)
-	userRepository := repository.NewUserRepository(userDao, userCache)
-	logger := ioc.InitLogger()
+	userCache := ioc.InitUserCache(cmdable, registerer)
+	redisHealthChecker := ioc.InitRedisHealthChecker(cmdable, logger)
+	userRepository := ioc.InitUserRepository(userDao, userCache, redisHealthChecker, logger)
 	userService := service.NewUserService(userRepository, logger)
-	codeCache := cache.NewCodeCache(cmdable)
-	codeRepository := repository.NewCodeRepository(codeCache)
-	smsService := ioc.InitSmsService(cmdable)
-	codeService := service.NewCodeService(codeRepository, smsService)
-	userHandler := web.NewUserHandler(userService, codeService)
-	engine := ioc.InitWebServer(v, userHandler)
-	return engine
+	codeStore := ioc.InitCodeStore(cmdable, redisHealthChecker)
+	codeRepository := ioc.InitCodeRepository(codeStore)
+	smsQuotaCache := ioc.InitSMSQuotaCache(cmdable)
+	smsQuotaRepository := repository.NewSMSQuotaRepository(smsQuotaCache, redisHealthChecker)
+	smsLogDao := dao.NewSMSLogDao(db)
+	smsLogRepository := repository.NewSMSLogRepository(smsLogDao)
+	smsService := ioc.InitSmsService(cmdable, smsLogRepository, registerer, logger)
+	voiceService := ioc.InitVoiceService(logger)
+	codePolicies := ioc.InitCodePolicies()
+	codeService := service.NewCodeService(codeRepository, smsQuotaRepository, smsService, voiceService, codePolicies)
+	wrapper := web.NewWrapper(logger)
+	userHandler := web.NewUserHandler(userService, codeService, jwtHandler, wrapper)
+	smsHandler := ioc.InitSMSHandler(cmdable, smsLogRepository, wrapper, registerer, logger)
+	smsLogService := service.NewSMSLogService(smsLogRepository)
+	smsLogHandler := ioc.InitSMSLogHandler(smsLogService, wrapper)
+	engine := ioc.InitWebServer(v, userHandler, smsHandler, smsLogHandler, rateLimitRules, redisHealthChecker, codeStore, wrapper, atomicLevel)
+	app := ioc.InitApp(engine, logger)
+	return app
+}
+
+func InitMigrator() (*migrate.Migrator, func(), error) {
+	atomicLevel := ioc.InitLogLevel()
+	logger := ioc.InitLogger(atomicLevel)
+	migrator, cleanup, err := ioc.InitMigrator(logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	return migrator, func() {
+		cleanup()
+	}, nil
 }